@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -36,46 +38,262 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := database.SetWithdrawalOrderUnique(db, cfg.WithdrawUniqueOrder); err != nil {
+		slog.Error("failed to apply withdrawal order policy", "error", err)
+		os.Exit(1)
+	}
+
+	limits := service.WithdrawalLimits{
+		MinAmount:     cfg.WithdrawMinAmount,
+		MaxSingle:     cfg.WithdrawMaxSingle,
+		DailyCap:      cfg.WithdrawDailyCap,
+		MonthlyCap:    cfg.WithdrawMonthlyCap,
+		MinAccountAge: cfg.WithdrawMinAccountAge,
+	}
+
+	tiers, err := service.ParseTiers(cfg.Tiers)
+	if err != nil {
+		slog.Error("invalid tiers", "error", err)
+		os.Exit(1)
+	}
+	if cfg.TierBasis != service.TierBasisLifetime && cfg.TierBasis != service.TierBasisRolling {
+		slog.Error("invalid tier basis", "basis", cfg.TierBasis)
+		os.Exit(1)
+	}
+
+	var keys *service.Keyset
+	if cfg.JWTKeys != "" {
+		keys, err = service.LoadKeyset(cfg.JWTKeys)
+	} else {
+		if cfg.JWTSecret == config.DefaultJWTSecret && !cfg.DevMode {
+			slog.Error("refusing to start with the default jwt secret outside dev mode, set JWT_SECRET or JWT_KEYS")
+			os.Exit(1)
+		}
+		keys, err = service.NewHMACKeyset("default", []byte(cfg.JWTSecret))
+	}
+	if err != nil {
+		slog.Error("invalid jwt keys", "error", err)
+		os.Exit(1)
+	}
+
+	cookies := handler.AuthCookies{Enabled: cfg.AuthCookies, Secure: cfg.CookieSecure}
+	switch cfg.CookieSameSite {
+	case "lax":
+		cookies.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookies.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookies.SameSite = http.SameSiteNoneMode
+	default:
+		slog.Error("invalid cookie samesite mode", "mode", cfg.CookieSameSite)
+		os.Exit(1)
+	}
+	if cookies.SameSite == http.SameSiteNoneMode && !cookies.Secure {
+		slog.Error("samesite none cookies must be secure")
+		os.Exit(1)
+	}
+
 	// Services
 	authSvc := service.NewAuthService(db)
+	tokenSvc := service.NewTokenService(db, keys, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	tierSvc := service.NewTierService(tiers, cfg.TierBasis, cfg.TierMultipliers)
 	orderSvc := service.NewOrderService(db)
-	balanceSvc := service.NewBalanceService(db)
-	withdrawalSvc := service.NewWithdrawalService(db)
+	campaignSvc := service.NewCampaignService(db, tierSvc)
+	referralSvc := service.NewReferralService(db, service.ReferralPolicy{
+		Bonus:          cfg.ReferralBonus,
+		MaxPerReferrer: cfg.ReferralMaxPerReferrer,
+		MinAccrual:     cfg.ReferralMinAccrual,
+	})
+	orderSvc.AddHook(tierSvc)
+	orderSvc.AddHook(campaignSvc)
+	orderSvc.AddHook(referralSvc)
+	profileSvc := service.NewProfileService(db, tierSvc)
+	expirySvc := service.NewExpiryService(db, cfg.PointsTTL)
+	balanceSvc := service.NewBalanceService(db, limits, expirySvc)
+	withdrawalSvc := service.NewWithdrawalService(db, limits, service.WithdrawalOrderPolicy{
+		UniqueOrder:        cfg.WithdrawUniqueOrder,
+		RejectForeignOrder: cfg.WithdrawRejectForeignOrder,
+	}, cfg.WithdrawApprovalThreshold)
+	scheduleSvc := service.NewScheduleService(db, withdrawalSvc)
+	walletSvc := service.NewWalletService(db)
+	rewardSvc := service.NewRewardService(db, withdrawalSvc)
+	spendCodeSvc := service.NewSpendCodeService(db, withdrawalSvc, cfg.SpendCodeTTL)
+	merchantSvc := service.NewMerchantService(db, cfg.MerchantKeyRotationGrace)
+	transferSvc := service.NewTransferService(db, service.TransferLimits{
+		MinAmount: cfg.TransferMinAmount,
+		MaxSingle: cfg.TransferMaxSingle,
+		DailyCap:  cfg.TransferDailyCap,
+	})
+	notificationSvc := service.NewNotificationService(db)
 	accrualClient := service.NewAccrualClient(cfg.AccrualSystemAddress)
 
-	// Worker
+	payoutProvider, err := newPayoutProvider(cfg)
+	if err != nil {
+		slog.Error("failed to init payout provider", "error", err)
+		os.Exit(1)
+	}
+	var payoutSvc *service.PayoutService
+	if payoutProvider != nil {
+		payoutSvc = service.NewPayoutService(db, payoutProvider, withdrawalSvc)
+	}
+
+	// Workers
 	accrualWorker := worker.NewAccrualWorker(orderSvc, accrualClient)
+	holdSweeper := worker.NewHoldSweeper(withdrawalSvc)
+	scheduleWorker := worker.NewScheduleWorker(scheduleSvc)
 
 	// Router
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	// credentials (auth cookies) are only accepted from configured origins;
+	// without any, every origin may call the API with bearer tokens only
+	corsOrigins := []string{"*"}
+	if cfg.CORSOrigins != "" {
+		corsOrigins = strings.Split(cfg.CORSOrigins, ",")
+		for i := range corsOrigins {
+			corsOrigins[i] = strings.TrimSpace(corsOrigins[i])
+		}
+	}
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
+		AllowedOrigins:   corsOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", mw.AdminTokenHeader, mw.APIKeyHeader, mw.CSRFHeader},
 		ExposedHeaders:   []string{"Authorization"},
-		AllowCredentials: true,
+		AllowCredentials: cfg.CORSOrigins != "",
 		MaxAge:           300,
 	}))
 
 	// Public routes
-	r.Post("/api/user/register", handler.RegisterHandler(authSvc, cfg.JWTSecret))
-	r.Post("/api/user/login", handler.LoginHandler(authSvc, cfg.JWTSecret))
+	r.Post("/api/user/register", handler.RegisterHandler(authSvc, tokenSvc, cookies))
+	r.Post("/api/user/login", handler.LoginHandler(authSvc, tokenSvc, cookies))
+	r.Post("/api/user/token/refresh", handler.RefreshTokenHandler(tokenSvc, cookies))
+	r.Get("/.well-known/jwks.json", handler.JWKSHandler(keys))
 
 	// Protected routes
 	r.Group(func(r chi.Router) {
-		r.Use(mw.AuthMiddleware(cfg.JWTSecret))
+		r.Use(mw.AuthMiddleware(keys, tokenSvc))
+
+		r.Post("/api/user/logout", handler.LogoutHandler(tokenSvc, cookies))
+		r.Post("/api/user/logout-all", handler.LogoutAllHandler(tokenSvc, cookies))
+		r.Get("/api/user/sessions", handler.ListSessionsHandler(tokenSvc))
+		r.Delete("/api/user/sessions/{id}", handler.TerminateSessionHandler(tokenSvc))
 
 		r.Post("/api/user/orders", handler.UploadOrderHandler(orderSvc))
 		r.Get("/api/user/orders", handler.ListOrdersHandler(orderSvc))
+		r.Get("/api/user/orders/export", handler.ExportOrdersHandler(orderSvc))
 
 		r.Get("/api/user/balance", handler.GetBalanceHandler(balanceSvc))
+		r.Get("/api/user/statement", handler.StatementHandler(balanceSvc))
 		r.Post("/api/user/balance/withdraw", handler.WithdrawHandler(withdrawalSvc))
+		r.Post("/api/user/balance/reserve", handler.ReserveHandler(withdrawalSvc, cfg.WithdrawalHoldTTL))
+		r.Post("/api/user/withdrawals/{id}/confirm", handler.ConfirmWithdrawalHandler(withdrawalSvc))
+		r.Post("/api/user/withdrawals/{id}/cancel", handler.CancelWithdrawalHandler(withdrawalSvc))
+
+		if payoutSvc != nil {
+			r.Post("/api/user/balance/cashout", handler.CashoutHandler(payoutSvc))
+		}
+
+		r.Post("/api/user/balance/transfer", handler.TransferHandler(transferSvc))
+		r.Get("/api/user/transfers", handler.ListTransfersHandler(transferSvc))
+
+		r.Post("/api/user/wallets", handler.CreateWalletHandler(walletSvc))
+		r.Get("/api/user/wallets", handler.ListWalletsHandler(walletSvc))
+		r.Get("/api/user/wallets/invites", handler.ListWalletInvitesHandler(walletSvc))
+		r.Post("/api/user/wallets/invites/{id}/accept", handler.AcceptWalletInviteHandler(walletSvc))
+		r.Post("/api/user/wallets/invites/{id}/decline", handler.DeclineWalletInviteHandler(walletSvc))
+		r.Get("/api/user/wallets/{id}", handler.GetWalletHandler(walletSvc))
+		r.Get("/api/user/wallets/{id}/balance", handler.GetWalletBalanceHandler(balanceSvc))
+		r.Post("/api/user/wallets/{id}/withdraw", handler.WalletWithdrawHandler(withdrawalSvc))
+		r.Post("/api/user/wallets/{id}/invites", handler.InviteWalletMemberHandler(walletSvc))
+		r.Put("/api/user/wallets/{id}/members/{login}", handler.UpdateWalletMemberHandler(walletSvc))
+		r.Delete("/api/user/wallets/{id}/members/{login}", handler.RemoveWalletMemberHandler(walletSvc))
+		r.Put("/api/user/wallets/{id}/routing", handler.SetWalletRoutingHandler(walletSvc))
+
+		r.Get("/api/user/rewards", handler.ListRewardsHandler(rewardSvc, true))
+		r.Post("/api/user/rewards/{id}/redeem", handler.RedeemRewardHandler(rewardSvc))
+		r.Get("/api/user/redemptions", handler.ListRedemptionsHandler(rewardSvc))
+
+		r.Post("/api/user/spend-codes", handler.IssueSpendCodeHandler(spendCodeSvc))
+
+		r.Post("/api/user/merchant-consents", handler.GrantMerchantConsentHandler(merchantSvc))
+		r.Get("/api/user/merchant-consents", handler.ListMerchantConsentsHandler(merchantSvc))
+		r.Delete("/api/user/merchant-consents/{id}", handler.RevokeMerchantConsentHandler(merchantSvc))
+
+		r.Post("/api/user/schedules", handler.CreateScheduleHandler(scheduleSvc))
+		r.Get("/api/user/schedules", handler.ListSchedulesHandler(scheduleSvc))
+		r.Delete("/api/user/schedules/{id}", handler.DeleteScheduleHandler(scheduleSvc))
+		r.Get("/api/user/schedules/{id}/runs", handler.ListScheduleRunsHandler(scheduleSvc))
+
+		r.Get("/api/user/profile", handler.GetProfileHandler(profileSvc))
+		r.Get("/api/user/referrals", handler.ListReferralsHandler(referralSvc))
+
+		r.Get("/api/user/notifications", handler.ListNotificationsHandler(notificationSvc))
+		r.Post("/api/user/notifications/read", handler.MarkNotificationsReadHandler(notificationSvc))
 		r.Get("/api/user/withdrawals", handler.ListWithdrawalsHandler(withdrawalSvc))
+		r.Get("/api/user/withdrawals/export", handler.ExportWithdrawalsHandler(withdrawalSvc))
 	})
 
+	// Admin routes
+	r.Group(func(r chi.Router) {
+		r.Use(mw.AdminMiddleware(cfg.AdminToken))
+
+		r.Get("/api/admin/withdrawals", handler.ListWithdrawalsForReviewHandler(withdrawalSvc))
+		r.Post("/api/admin/withdrawals/{id}/approve", handler.ApproveWithdrawalHandler(withdrawalSvc))
+		r.Post("/api/admin/withdrawals/{id}/reject", handler.RejectWithdrawalHandler(withdrawalSvc))
+		r.Post("/api/admin/withdrawals/{id}/reversals", handler.ReverseWithdrawalHandler(withdrawalSvc))
+
+		r.Post("/api/admin/campaigns", handler.CreateCampaignHandler(campaignSvc))
+		r.Get("/api/admin/campaigns", handler.ListCampaignsHandler(campaignSvc))
+		r.Get("/api/admin/campaigns/{id}", handler.GetCampaignHandler(campaignSvc))
+		r.Put("/api/admin/campaigns/{id}", handler.UpdateCampaignHandler(campaignSvc))
+		r.Delete("/api/admin/campaigns/{id}", handler.DeactivateCampaignHandler(campaignSvc))
+
+		r.Post("/api/admin/rewards", handler.CreateRewardHandler(rewardSvc))
+		r.Get("/api/admin/rewards", handler.ListRewardsHandler(rewardSvc, false))
+		r.Get("/api/admin/rewards/{id}", handler.GetRewardHandler(rewardSvc))
+		r.Put("/api/admin/rewards/{id}", handler.UpdateRewardHandler(rewardSvc))
+		r.Delete("/api/admin/rewards/{id}", handler.DeactivateRewardHandler(rewardSvc))
+
+		r.Post("/api/admin/merchants", handler.CreateMerchantHandler(merchantSvc))
+		r.Get("/api/admin/merchants", handler.ListMerchantsHandler(merchantSvc))
+		r.Post("/api/admin/merchants/{id}/keys", handler.IssueMerchantKeyHandler(merchantSvc))
+		r.Get("/api/admin/merchants/{id}/keys", handler.ListMerchantKeysHandler(merchantSvc))
+		r.Post("/api/admin/merchants/{id}/keys/{keyID}/rotate", handler.RotateMerchantKeyHandler(merchantSvc))
+		r.Delete("/api/admin/merchants/{id}/keys/{keyID}", handler.RevokeMerchantKeyHandler(merchantSvc))
+		r.Post("/api/admin/merchants/{id}/signing-secret", handler.SetMerchantSigningSecretHandler(merchantSvc))
+		r.Delete("/api/admin/merchants/{id}/signing-secret", handler.ClearMerchantSigningSecretHandler(merchantSvc))
+	})
+
+	// signed server-to-server requests share one replay window
+	nonces := mw.NewNonceCache(2 * cfg.SignatureMaxSkew)
+
+	// Merchant routes
+	r.Group(func(r chi.Router) {
+		r.Use(mw.MerchantAuthMiddleware(merchantSvc))
+		r.Use(mw.SignatureMiddleware(mw.MerchantSigningSecret(merchantSvc), nonces, cfg.SignatureMaxSkew))
+
+		r.With(mw.RequireScope(service.ScopeOrdersWrite)).
+			Post("/api/merchant/orders", handler.MerchantRegisterOrderHandler(merchantSvc, orderSvc))
+		r.With(mw.RequireScope(service.ScopeBalanceRead)).
+			Get("/api/merchant/users/{login}/balance", handler.MerchantBalanceHandler(merchantSvc, balanceSvc))
+		r.With(mw.RequireScope(service.ScopeWithdrawalRefund)).
+			Post("/api/merchant/withdrawals/{id}/refund", handler.MerchantRefundHandler(merchantSvc, withdrawalSvc))
+		r.With(mw.RequireScope(service.ScopeSpendCodesRedeem)).
+			Post("/api/merchant/spend-codes/redeem", handler.RedeemSpendCodeHandler(spendCodeSvc))
+	})
+
+	// Accrual system callbacks
+	r.With(mw.SignatureMiddleware(mw.StaticSigningSecret(cfg.AccrualCallbackSecret), nonces, cfg.SignatureMaxSkew)).
+		Post("/api/accrual/callback", handler.AccrualCallbackHandler(orderSvc))
+
+	// Payout provider callbacks
+	if payoutSvc != nil {
+		r.With(mw.SharedSecretMiddleware("X-Payout-Secret", cfg.PayoutCallbackSecret)).
+			Post("/api/payouts/callback", handler.PayoutCallbackHandler(payoutSvc))
+	}
+
 	srv := &http.Server{
 		Addr:         cfg.RunAddress,
 		Handler:      r,
@@ -85,6 +303,14 @@ func main() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go accrualWorker.Start(ctx)
+	go holdSweeper.Start(ctx)
+	go scheduleWorker.Start(ctx)
+	if expirySvc.Enabled() {
+		go worker.NewExpiryWorker(expirySvc).Start(ctx)
+	}
+	if payoutSvc != nil {
+		go worker.NewPayoutWorker(payoutSvc).Start(ctx)
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -99,7 +325,7 @@ func main() {
 	<-quit
 	slog.Info("shutting down...")
 
-	cancel() // stop worker
+	cancel() // stop workers
 	ctxShut, cancelShut := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancelShut()
 
@@ -109,3 +335,19 @@ func main() {
 
 	slog.Info("server stopped")
 }
+
+func newPayoutProvider(cfg *config.Config) (service.PayoutProvider, error) {
+	switch cfg.PayoutProvider {
+	case "":
+		return nil, nil
+	case "file":
+		return service.NewFilePayoutProvider(cfg.PayoutDir)
+	case "http":
+		if cfg.PayoutURL == "" {
+			return nil, fmt.Errorf("payout url is required for the http provider")
+		}
+		return service.NewHTTPPayoutProvider(cfg.PayoutURL), nil
+	default:
+		return nil, fmt.Errorf("unknown payout provider %q", cfg.PayoutProvider)
+	}
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// backfill is a one-off data fix that must run exactly once per database,
+// unlike schemaSQL which is replayed on every start.
+type backfill struct {
+	name  string
+	query string
+}
+
+var backfills = []backfill{
+	{
+		// Orders processed before accrued_at was tracked count as accrued
+		// when they were uploaded.
+		name:  "orders_accrued_at",
+		query: `UPDATE orders SET accrued_at = uploaded_at WHERE status = 'PROCESSED' AND accrued_at IS NULL`,
+	},
+	{
+		// Balances accrued before lots existed become one "migrated" lot per
+		// user, covering whatever the user's lots don't. It is dated like the
+		// user's oldest lot so it is spent and expires no later than the
+		// points that came after it.
+		name: "accrual_lots_migrated",
+		query: `
+			INSERT INTO accrual_lots (user_id, source, amount, remaining, created_at)
+			SELECT u.id, 'migrated', u.current_balance - l.held, u.current_balance - l.held, COALESCE(l.oldest, NOW())
+			FROM users u
+			CROSS JOIN LATERAL (
+				SELECT COALESCE(SUM(remaining), 0) AS held, MIN(created_at) AS oldest
+				FROM accrual_lots WHERE user_id = u.id AND remaining > 0
+			) l
+			WHERE u.current_balance > l.held
+		`,
+	},
+}
+
+// runBackfills applies the backfills this database has not seen yet. Each
+// one is recorded in schema_backfills in the same transaction it runs in,
+// and the marker row's key serializes instances starting at the same time.
+func runBackfills(db *sql.DB) error {
+	for _, b := range backfills {
+		if err := runBackfill(db, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runBackfill(db *sql.DB, b backfill) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO schema_backfills (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, b.name)
+	if err != nil {
+		return fmt.Errorf("mark backfill %s: %w", b.name, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("mark backfill %s: %w", b.name, err)
+	} else if n == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(b.query); err != nil {
+		return fmt.Errorf("run backfill %s: %w", b.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit backfill %s: %w", b.name, err)
+	}
+	return nil
+}
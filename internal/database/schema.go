@@ -34,9 +34,361 @@ CREATE TABLE IF NOT EXISTS withdrawals (
     processed_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS refunded NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS withdrawal_reversals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    withdrawal_id UUID NOT NULL REFERENCES withdrawals(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sum NUMERIC(10,2) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdrawal_reversals_withdrawal_id ON withdrawal_reversals(withdrawal_id);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_reversals_user_id ON withdrawal_reversals(user_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS held NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'CONFIRMED';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS released_at TIMESTAMPTZ;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+UPDATE withdrawals SET created_at = processed_at WHERE created_at IS NULL;
+ALTER TABLE withdrawals ALTER COLUMN created_at SET DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_withdrawals_reserved_expires ON withdrawals(expires_at) WHERE status = 'RESERVED';
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS order_duplicate BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS review_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_withdrawals_pending_approval ON withdrawals(created_at) WHERE status = 'PENDING_APPROVAL';
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'ORDER';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS destination TEXT NOT NULL DEFAULT '';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_status TEXT NOT NULL DEFAULT '';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_ref TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_withdrawals_payout_ref ON withdrawals(payout_ref) WHERE payout_ref IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_withdrawals_payout_status ON withdrawals(payout_status) WHERE payout_status IN ('NEW', 'PENDING');
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_retry_at TIMESTAMPTZ;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_error TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_withdrawals_payout_status;
+CREATE INDEX IF NOT EXISTS idx_withdrawals_payout_open ON withdrawals(payout_status) WHERE payout_status IN ('NEW', 'SUBMITTING', 'PENDING');
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    read_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS withdrawal_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reference TEXT NOT NULL,
+    sum NUMERIC(10,2) NOT NULL,
+    period TEXT NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    failures INT NOT NULL DEFAULT 0,
+    last_run_at TIMESTAMPTZ,
+    last_status TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS withdrawal_schedule_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_id UUID NOT NULL REFERENCES withdrawal_schedules(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    withdrawal_id UUID REFERENCES withdrawals(id) ON DELETE SET NULL,
+    error TEXT NOT NULL DEFAULT '',
+    ran_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_schedules_user_id ON withdrawal_schedules(user_id);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_schedules_due ON withdrawal_schedules(next_run_at) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_withdrawal_schedule_runs_schedule_id ON withdrawal_schedule_runs(schedule_id, ran_at);
+
+ALTER TABLE withdrawal_schedules ADD COLUMN IF NOT EXISTS retry_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE withdrawal_schedules ADD COLUMN IF NOT EXISTS retry_at TIMESTAMPTZ;
+ALTER TABLE withdrawal_schedules ADD COLUMN IF NOT EXISTS anchor_day INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS accrual_lots (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    amount NUMERIC(10,2) NOT NULL,
+    remaining NUMERIC(10,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expired_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS lot_consumptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    lot_id UUID NOT NULL REFERENCES accrual_lots(id) ON DELETE CASCADE,
+    withdrawal_id UUID NOT NULL REFERENCES withdrawals(id) ON DELETE CASCADE,
+    amount NUMERIC(10,2) NOT NULL,
+    restored NUMERIC(10,2) NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS point_expiries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    lot_id UUID NOT NULL REFERENCES accrual_lots(id) ON DELETE CASCADE,
+    sum NUMERIC(10,2) NOT NULL,
+    expired_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_lots_user_open ON accrual_lots(user_id, created_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_accrual_lots_open ON accrual_lots(created_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_lot_consumptions_withdrawal_id ON lot_consumptions(withdrawal_id);
+CREATE INDEX IF NOT EXISTS idx_point_expiries_user_id ON point_expiries(user_id, expired_at);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS accrued_at TIMESTAMPTZ;
+
 CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);
 CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
 CREATE INDEX IF NOT EXISTS idx_withdrawals_user_id ON withdrawals(user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_user_status ON orders(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_orders_user_uploaded ON orders(user_id, uploaded_at);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_user_processed ON withdrawals(user_id, processed_at);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier_updated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS bonuses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    order_number TEXT NOT NULL DEFAULT '',
+    campaign_id UUID,
+    amount NUMERIC(10,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bonuses_user_id ON bonuses(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_accrued ON orders(user_id, accrued_at) WHERE status = 'PROCESSED';
+
+CREATE TABLE IF NOT EXISTS campaigns (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    value NUMERIC(10,2) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    min_tier TEXT NOT NULL DEFAULT '',
+    first_order_only BOOLEAN NOT NULL DEFAULT FALSE,
+    min_orders INTEGER NOT NULL DEFAULT 0,
+    max_orders INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_window ON campaigns(starts_at, ends_at) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_bonuses_campaign_id ON bonuses(campaign_id) WHERE campaign_id IS NOT NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code TEXT;
+-- Users from before referrals get a code drawn like newReferralCode does;
+-- codes are short, so a taken one is drawn again before the index is built.
+DO $$
+DECLARE
+    u RECORD;
+    c TEXT;
+BEGIN
+    FOR u IN SELECT id FROM users WHERE referral_code IS NULL LOOP
+        LOOP
+            c := UPPER(SUBSTR(MD5(uuid_generate_v4()::text), 1, 8));
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM users WHERE referral_code = c);
+        END LOOP;
+        UPDATE users SET referral_code = c WHERE id = u.id;
+    END LOOP;
+END
+$$;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_referral_code ON users(referral_code);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    referee_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    bonus NUMERIC(10,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    rewarded_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals(referrer_id, created_at);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount NUMERIC(10,2) NOT NULL CHECK (amount > 0),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from_user_id ON transfers(from_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transfers_to_user_id ON transfers(to_user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS wallets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    current_balance NUMERIC(10,2) NOT NULL DEFAULT 0,
+    withdrawn NUMERIC(10,2) NOT NULL DEFAULT 0,
+    held NUMERIC(10,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS wallet_members (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    can_withdraw BOOLEAN NOT NULL DEFAULT FALSE,
+    max_withdrawal NUMERIC(10,2) NOT NULL DEFAULT 0,
+    route_accruals BOOLEAN NOT NULL DEFAULT FALSE,
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS wallet_invites (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    inviter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invitee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    responded_at TIMESTAMPTZ
+);
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS wallet_id UUID REFERENCES wallets(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS wallet_id UUID REFERENCES wallets(id);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_members_user_id ON wallet_members(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_wallet_members_routing ON wallet_members(user_id) WHERE route_accruals;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_wallet_invites_pending ON wallet_invites(wallet_id, invitee_id) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_wallet_invites_invitee_id ON wallet_invites(invitee_id) WHERE status = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS rewards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    price NUMERIC(10,2) NOT NULL CHECK (price > 0),
+    stock INTEGER CHECK (stock >= 0),
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reward_id UUID NOT NULL REFERENCES rewards(id),
+    withdrawal_id UUID NOT NULL UNIQUE REFERENCES withdrawals(id),
+    code TEXT NOT NULL UNIQUE,
+    price NUMERIC(10,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_redemptions_user_id ON redemptions(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS spend_codes (
+    code TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    withdrawal_id UUID NOT NULL UNIQUE REFERENCES withdrawals(id) ON DELETE CASCADE,
+    merchant_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    redeemed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_spend_codes_user_id ON spend_codes(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS merchants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS merchant_api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS merchant_consents (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, merchant_id)
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS merchant_id UUID REFERENCES merchants(id);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_api_keys_merchant_id ON merchant_api_keys(merchant_id);
+CREATE INDEX IF NOT EXISTS idx_orders_merchant_id ON orders(merchant_id) WHERE merchant_id IS NOT NULL;
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS signing_secret TEXT;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS signing_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+    jti UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_tokens_family_id ON access_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_access_tokens_user_id ON access_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    terminated_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id) WHERE terminated_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS schema_backfills (
+    name TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ DEFAULT NOW()
+);
 `
 
 func InitSchema(db *sql.DB) error {
@@ -44,5 +396,40 @@ func InitSchema(db *sql.DB) error {
 	if err != nil {
 		return fmt.Errorf("failed to init schema: %w", err)
 	}
+	if err := runBackfills(db); err != nil {
+		return fmt.Errorf("failed to init schema: %w", err)
+	}
+	return nil
+}
+
+const withdrawalOrderUniqueSQL = `
+DROP INDEX IF EXISTS uq_withdrawals_active_order_number;
+UPDATE withdrawals SET order_duplicate = TRUE
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY order_number ORDER BY processed_at, id) AS n
+        FROM withdrawals
+        WHERE status IN ('RESERVED', 'CONFIRMED', 'PENDING_APPROVAL') AND refunded < sum AND NOT order_duplicate
+    ) d
+    WHERE d.n > 1
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_withdrawals_active_order
+    ON withdrawals(order_number)
+    WHERE status IN ('RESERVED', 'CONFIRMED', 'PENDING_APPROVAL') AND refunded < sum AND NOT order_duplicate;
+`
+
+// SetWithdrawalOrderUnique creates or drops the index that backs the
+// one-withdrawal-per-order policy at the DB level. Withdrawals that already
+// share an order number (made while the policy was off) are flagged as
+// order_duplicate first so the index can be built; only the earliest one
+// per order stays covered by it.
+func SetWithdrawalOrderUnique(db *sql.DB, enabled bool) error {
+	query := `DROP INDEX IF EXISTS uq_withdrawals_active_order_number; DROP INDEX IF EXISTS uq_withdrawals_active_order;`
+	if enabled {
+		query = withdrawalOrderUniqueSQL
+	}
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to set withdrawal order uniqueness: %w", err)
+	}
 	return nil
 }
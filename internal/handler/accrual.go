@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/service"
+)
+
+// AccrualCallbackHandler lets the accrual system push order results instead
+// of waiting for the worker to poll them. The body has the same shape as
+// the accrual system's GET /api/orders/{number} response.
+func AccrualCallbackHandler(orderSvc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req service.AccrualResponse
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Order == "" {
+			http.Error(w, "order required", http.StatusBadRequest)
+			return
+		}
+
+		switch req.Status {
+		case "REGISTERED", "PROCESSING", "INVALID", "PROCESSED":
+		default:
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+
+		status, accrual := req.OrderUpdate()
+		if err := orderSvc.UpdateStatus(r.Context(), req.Order, status, accrual); err != nil {
+			slog.Error("accrual callback failed", "order", req.Order, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
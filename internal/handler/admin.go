@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/model"
+	"gophermart/internal/service"
+)
+
+type reverseWithdrawalRequest struct {
+	Sum    float64 `json:"sum"`
+	Reason string  `json:"reason"`
+}
+
+func ReverseWithdrawalHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req reverseWithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Sum < 0 {
+			http.Error(w, "invalid sum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		reversal, err := withdrawalSvc.Reverse(r.Context(), id, req.Sum, req.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrWithdrawalNotFound):
+				http.Error(w, "withdrawal not found", http.StatusNotFound)
+			case errors.Is(err, service.ErrWithdrawalState):
+				http.Error(w, "only confirmed withdrawals can be reversed", http.StatusConflict)
+			case errors.Is(err, service.ErrReversalExceedsWithdrawal):
+				http.Error(w, "reversal exceeds withdrawn sum", http.StatusUnprocessableEntity)
+			default:
+				slog.Error("withdrawal reversal failed", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(reversal); err != nil {
+			slog.Error("encode reversal failed", "error", err)
+		}
+	}
+}
+
+type reviewWithdrawalRequest struct {
+	Reason string `json:"reason"`
+}
+
+func ListWithdrawalsForReviewHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = service.WithdrawalPendingApproval
+		}
+
+		withdrawals, err := withdrawalSvc.ListByStatus(r.Context(), status, 100)
+		if err != nil {
+			slog.Error("list withdrawals failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(withdrawals); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func ApproveWithdrawalHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return reviewWithdrawalHandler(withdrawalSvc.Approve, false)
+}
+
+func RejectWithdrawalHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return reviewWithdrawalHandler(withdrawalSvc.Reject, true)
+}
+
+type reviewFunc func(ctx context.Context, withdrawalID, reason string) (*model.Withdrawal, error)
+
+func reviewWithdrawalHandler(review reviewFunc, reasonRequired bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req reviewWithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if reasonRequired && req.Reason == "" {
+			http.Error(w, "reason required", http.StatusBadRequest)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		withdrawal, err := review(r.Context(), id, req.Reason)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		writeWithdrawal(w, http.StatusOK, withdrawal)
+	}
+}
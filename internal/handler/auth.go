@@ -3,10 +3,13 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
-	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"gophermart/internal/model"
+	"gophermart/internal/mw"
 	"gophermart/internal/service"
 )
 
@@ -15,7 +18,11 @@ type loginRequest struct {
 	Password string `json:"password"`
 }
 
-func LoginHandler(authSvc *service.AuthService, secret string) http.HandlerFunc {
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func LoginHandler(authSvc *service.AuthService, tokenSvc *service.TokenService, cookies AuthCookies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -31,7 +38,7 @@ func LoginHandler(authSvc *service.AuthService, secret string) http.HandlerFunc
 		user, err := authSvc.Authenticate(r.Context(), req.Login, req.Password)
 		if err != nil {
 			switch {
-			case errors.Is(err, errors.New("invalid login or password")):
+			case errors.Is(err, service.ErrInvalidCredentials):
 				http.Error(w, "invalid login or password", http.StatusUnauthorized)
 			default:
 				http.Error(w, "internal error", http.StatusInternalServerError)
@@ -39,18 +46,145 @@ func LoginHandler(authSvc *service.AuthService, secret string) http.HandlerFunc
 			return
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"user_id": user.ID,
-			"exp":     jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		})
-
-		tokenString, err := token.SignedString([]byte(secret))
+		pair, err := tokenSvc.Issue(r.Context(), user.ID, clientInfo(r))
 		if err != nil {
 			http.Error(w, "token generation failed", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Authorization", "Bearer "+tokenString)
-		w.WriteHeader(http.StatusOK)
+		writeTokens(w, pair, cookies)
+	}
+}
+
+func RefreshTokenHandler(tokenSvc *service.TokenService, cookies AuthCookies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// browser clients send an empty body and the refresh cookie
+		var req refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.RefreshToken == "" {
+			if cookie, err := r.Cookie(mw.RefreshCookieName); err == nil {
+				if !mw.ValidCSRF(r) {
+					http.Error(w, "invalid csrf token", http.StatusForbidden)
+					return
+				}
+				req.RefreshToken = cookie.Value
+			}
+		}
+
+		if req.RefreshToken == "" {
+			http.Error(w, "refresh_token required", http.StatusBadRequest)
+			return
+		}
+
+		pair, err := tokenSvc.Refresh(r.Context(), req.RefreshToken, clientInfo(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			default:
+				slog.Error("refresh token failed", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		writeTokens(w, pair, cookies)
+	}
+}
+
+func LogoutHandler(tokenSvc *service.TokenService, cookies AuthCookies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+		jti := r.Context().Value(mw.TokenIDCtxKey).(string)
+
+		if err := tokenSvc.Logout(r.Context(), userID, jti); err != nil {
+			slog.Error("logout failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		cookies.clear(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func LogoutAllHandler(tokenSvc *service.TokenService, cookies AuthCookies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		if err := tokenSvc.LogoutAll(r.Context(), userID); err != nil {
+			slog.Error("logout all failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		cookies.clear(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// clientInfo describes the caller for the session list. The remote address
+// is used as is; deployments behind a proxy should put middleware.RealIP
+// in front.
+func clientInfo(r *http.Request) service.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return service.ClientInfo{UserAgent: r.UserAgent(), IP: ip}
+}
+
+func writeTokens(w http.ResponseWriter, pair *model.TokenPair, cookies AuthCookies) {
+	if err := cookies.set(w, pair); err != nil {
+		http.Error(w, "token generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	body := *pair
+	if cookies.Enabled {
+		body.AccessToken, body.RefreshToken = "", ""
+	} else {
+		w.Header().Set("Authorization", "Bearer "+pair.AccessToken)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("encode tokens failed", "error", err)
+	}
+}
+
+// JWKSHandler publishes the public keys other services use to verify our
+// access tokens.
+func JWKSHandler(keys *service.Keyset) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(keys.JWKS()); err != nil {
+			slog.Error("encode jwks failed", "error", err)
+		}
 	}
 }
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"gophermart/internal/mw"
 	"gophermart/internal/service"
@@ -17,15 +18,62 @@ func GetBalanceHandler(balanceSvc *service.BalanceService) http.HandlerFunc {
 
 		userID := r.Context().Value(mw.UserCtxKey).(string)
 
-		balance, err := balanceSvc.Get(r.Context(), userID)
+		var balance *service.Balance
+		var err error
+		if v := r.URL.Query().Get("at"); v != "" {
+			at, parseErr := time.Parse(time.RFC3339, v)
+			if parseErr != nil {
+				http.Error(w, "invalid at: expected RFC3339", http.StatusBadRequest)
+				return
+			}
+			balance, err = balanceSvc.GetAt(r.Context(), userID, at)
+		} else {
+			balance, err = balanceSvc.Get(r.Context(), userID)
+		}
 		if err != nil {
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
+		if r.URL.Query().Get("include") == "pending" {
+			balance.Pending, err = balanceSvc.Pending(r.Context(), userID)
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(balance); err != nil {
 			http.Error(w, "encode error", http.StatusInternalServerError)
 		}
 	}
 }
+
+func StatementHandler(balanceSvc *service.BalanceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		statement, err := balanceSvc.Statement(r.Context(), userID, period)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(statement); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
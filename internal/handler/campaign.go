@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/model"
+	"gophermart/internal/service"
+)
+
+func CreateCampaignHandler(campaignSvc *service.CampaignService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req := model.Campaign{Active: true}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		campaign, err := campaignSvc.Create(r.Context(), req)
+		if err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+
+		writeCampaign(w, http.StatusCreated, campaign)
+	}
+}
+
+func UpdateCampaignHandler(campaignSvc *service.CampaignService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		// fields the body omits keep their stored values, so a PUT without
+		// "active" doesn't switch the campaign off
+		current, err := campaignSvc.Get(r.Context(), id)
+		if err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+		req := *current
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		req.ID = current.ID
+
+		campaign, err := campaignSvc.Update(r.Context(), req)
+		if err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+
+		writeCampaign(w, http.StatusOK, campaign)
+	}
+}
+
+func GetCampaignHandler(campaignSvc *service.CampaignService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		campaign, err := campaignSvc.Get(r.Context(), id)
+		if err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+
+		writeCampaign(w, http.StatusOK, campaign)
+	}
+}
+
+func ListCampaignsHandler(campaignSvc *service.CampaignService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		campaigns, err := campaignSvc.List(r.Context())
+		if err != nil {
+			slog.Error("list campaigns failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(campaigns) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(campaigns); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func DeactivateCampaignHandler(campaignSvc *service.CampaignService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := campaignSvc.Deactivate(r.Context(), id); err != nil {
+			writeCampaignError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeCampaign(w http.ResponseWriter, status int, campaign *model.Campaign) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(campaign); err != nil {
+		slog.Error("encode campaign failed", "error", err)
+	}
+}
+
+func writeCampaignError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCampaignNotFound):
+		http.Error(w, "campaign not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidCampaign):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		slog.Error("campaign request failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
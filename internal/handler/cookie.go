@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"gophermart/internal/model"
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+// AuthCookies configures the cookie mode for browser clients. When enabled,
+// issued tokens are set as HttpOnly cookies together with a readable CSRF
+// cookie the frontend echoes in mw.CSRFHeader, and are left out of the
+// response so scripts never see them.
+type AuthCookies struct {
+	Enabled  bool
+	Secure   bool
+	SameSite http.SameSite
+}
+
+func (c AuthCookies) set(w http.ResponseWriter, pair *model.TokenPair) error {
+	if !c.Enabled {
+		return nil
+	}
+
+	csrf, err := service.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, c.cookie(mw.AccessCookieName, pair.AccessToken, "/", pair.ExpiresAt, true))
+	http.SetCookie(w, c.cookie(mw.RefreshCookieName, pair.RefreshToken, mw.RefreshCookiePath, pair.RefreshExpiresAt, true))
+	http.SetCookie(w, c.cookie(mw.CSRFCookieName, csrf, "/", pair.RefreshExpiresAt, false))
+	return nil
+}
+
+func (c AuthCookies) clear(w http.ResponseWriter) {
+	if !c.Enabled {
+		return
+	}
+
+	expired := time.Unix(0, 0)
+	http.SetCookie(w, c.cookie(mw.AccessCookieName, "", "/", expired, true))
+	http.SetCookie(w, c.cookie(mw.RefreshCookieName, "", mw.RefreshCookiePath, expired, true))
+	http.SetCookie(w, c.cookie(mw.CSRFCookieName, "", "/", expired, false))
+}
+
+func (c AuthCookies) cookie(name, value, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		HttpOnly: httpOnly,
+		Secure:   c.Secure,
+		SameSite: c.SameSite,
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gophermart/internal/model"
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+const exportFlushEvery = 100
+
+// exportWriter streams rows to the client in the requested format and
+// flushes periodically so large histories are never buffered in memory.
+type exportWriter struct {
+	rc   *http.ResponseController
+	csv  *csv.Writer
+	json *json.Encoder
+	rows int
+}
+
+func newExportWriter(w http.ResponseWriter, r *http.Request, name string) (*exportWriter, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	ew := &exportWriter{rc: http.NewResponseController(w)}
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), format)
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		ew.csv = csv.NewWriter(w)
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		ew.json = json.NewEncoder(w)
+	default:
+		http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+		return nil, false
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// exports may outlive the server-wide write timeout
+	_ = ew.rc.SetWriteDeadline(time.Time{})
+
+	return ew, true
+}
+
+func (ew *exportWriter) header(fields []string) error {
+	if ew.csv == nil {
+		return nil
+	}
+	return ew.csv.Write(fields)
+}
+
+func (ew *exportWriter) write(fields []string, v any) error {
+	var err error
+	if ew.csv != nil {
+		err = ew.csv.Write(fields)
+	} else {
+		err = ew.json.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	ew.rows++
+	if ew.rows%exportFlushEvery == 0 {
+		return ew.flush()
+	}
+	return nil
+}
+
+func (ew *exportWriter) flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return ew.rc.Flush()
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func ExportOrdersHandler(orderSvc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ew, ok := newExportWriter(w, r, "orders")
+		if !ok {
+			return
+		}
+
+		if err := ew.header([]string{"number", "status", "accrual", "uploaded_at"}); err != nil {
+			slog.Error("orders export failed", "error", err)
+			return
+		}
+
+		err = orderSvc.StreamByUser(r.Context(), userID, period, func(o model.Order) error {
+			return ew.write([]string{o.Number, o.Status, formatAmount(o.Accrual), o.UploadedAt.Format(time.RFC3339)}, o)
+		})
+		if err != nil {
+			// headers are already sent, the client sees a truncated file
+			slog.Error("orders export failed", "error", err)
+			return
+		}
+
+		if err := ew.flush(); err != nil {
+			slog.Error("orders export failed", "error", err)
+		}
+	}
+}
+
+func ExportWithdrawalsHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ew, ok := newExportWriter(w, r, "withdrawals")
+		if !ok {
+			return
+		}
+
+		if err := ew.header([]string{"order", "sum", "status", "processed_at"}); err != nil {
+			slog.Error("withdrawals export failed", "error", err)
+			return
+		}
+
+		err = withdrawalSvc.StreamByUser(r.Context(), userID, period, func(wd model.Withdrawal) error {
+			return ew.write([]string{wd.OrderNumber, formatAmount(wd.Sum), wd.Status, wd.ProcessedAt.Format(time.RFC3339)}, wd)
+		})
+		if err != nil {
+			slog.Error("withdrawals export failed", "error", err)
+			return
+		}
+
+		if err := ew.flush(); err != nil {
+			slog.Error("withdrawals export failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+type createMerchantRequest struct {
+	Name string `json:"name"`
+}
+
+func CreateMerchantHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req createMerchantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+
+		merchant, err := merchantSvc.Create(r.Context(), req.Name)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusCreated, merchant)
+	}
+}
+
+func ListMerchantsHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		merchants, err := merchantSvc.List(r.Context())
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		if len(merchants) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusOK, merchants)
+	}
+}
+
+type issueKeyRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+func IssueMerchantKeyHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req issueKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		key, err := merchantSvc.IssueKey(r.Context(), id, req.Scopes)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusCreated, key)
+	}
+}
+
+func ListMerchantKeysHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		keys, err := merchantSvc.ListKeys(r.Context(), id)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusOK, keys)
+	}
+}
+
+func RotateMerchantKeyHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+		keyID, ok := idParam(w, r, "keyID")
+		if !ok {
+			return
+		}
+
+		key, err := merchantSvc.RotateKey(r.Context(), id, keyID)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusCreated, key)
+	}
+}
+
+func RevokeMerchantKeyHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+		keyID, ok := idParam(w, r, "keyID")
+		if !ok {
+			return
+		}
+
+		if err := merchantSvc.RevokeKey(r.Context(), id, keyID); err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type signingSecretRequest struct {
+	Required bool `json:"required"`
+}
+
+func SetMerchantSigningSecretHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req signingSecretRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		secret, err := merchantSvc.SetSigningSecret(r.Context(), id, req.Required)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusCreated, secret)
+	}
+}
+
+func ClearMerchantSigningSecretHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := merchantSvc.ClearSigningSecret(r.Context(), id); err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type grantConsentRequest struct {
+	MerchantID string `json:"merchant_id"`
+}
+
+func GrantMerchantConsentHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req grantConsentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.MerchantID == "" {
+			http.Error(w, "merchant_id required", http.StatusBadRequest)
+			return
+		}
+
+		if err := merchantSvc.GrantConsent(r.Context(), userID, req.MerchantID); err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListMerchantConsentsHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		consents, err := merchantSvc.ListConsents(r.Context(), userID)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		if len(consents) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusOK, consents)
+	}
+}
+
+func RevokeMerchantConsentHandler(merchantSvc *service.MerchantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := merchantSvc.RevokeConsent(r.Context(), userID, id); err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type merchantOrderRequest struct {
+	Login string `json:"login"`
+	Order string `json:"order"`
+}
+
+func MerchantRegisterOrderHandler(merchantSvc *service.MerchantService, orderSvc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		merchantID := r.Context().Value(mw.MerchantCtxKey).(string)
+
+		var req merchantOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Login == "" || req.Order == "" {
+			http.Error(w, "login and order required", http.StatusBadRequest)
+			return
+		}
+
+		if !validateLuhn(req.Order) {
+			http.Error(w, "invalid order number (failed Luhn check)", http.StatusUnprocessableEntity)
+			return
+		}
+
+		userID, err := merchantSvc.UserIDByLogin(r.Context(), req.Login)
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		err = orderSvc.CreateForMerchant(r.Context(), merchantID, userID, req.Order)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrOrderAlreadyExistsByUser):
+				w.WriteHeader(http.StatusOK)
+			case errors.Is(err, service.ErrOrderAlreadyExistsByOther):
+				http.Error(w, "order already uploaded by another user", http.StatusConflict)
+			default:
+				slog.Error("merchant order create failed", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func MerchantBalanceHandler(merchantSvc *service.MerchantService, balanceSvc *service.BalanceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		merchantID := r.Context().Value(mw.MerchantCtxKey).(string)
+
+		userID, err := merchantSvc.UserIDByLogin(r.Context(), chi.URLParam(r, "login"))
+		if err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		if err := merchantSvc.RequireConsent(r.Context(), userID, merchantID); err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		balance, err := balanceSvc.Get(r.Context(), userID)
+		if err != nil {
+			slog.Error("merchant balance lookup failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusOK, service.Balance{Current: balance.Current, Withdrawn: balance.Withdrawn})
+	}
+}
+
+func MerchantRefundHandler(merchantSvc *service.MerchantService, withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		merchantID := r.Context().Value(mw.MerchantCtxKey).(string)
+		withdrawalID, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var req reverseWithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Sum < 0 {
+			http.Error(w, "invalid sum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := merchantSvc.RequireOwnedWithdrawal(r.Context(), merchantID, withdrawalID); err != nil {
+			writeMerchantError(w, err)
+			return
+		}
+
+		reversal, err := withdrawalSvc.Reverse(r.Context(), withdrawalID, req.Sum, req.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrReversalExceedsWithdrawal):
+				http.Error(w, "refund exceeds withdrawn sum", http.StatusUnprocessableEntity)
+			case errors.Is(err, service.ErrWithdrawalState):
+				http.Error(w, "only confirmed withdrawals can be refunded", http.StatusConflict)
+			default:
+				writeWithdrawalError(w, err)
+			}
+			return
+		}
+
+		writeMerchantJSON(w, http.StatusCreated, reversal)
+	}
+}
+
+func writeMerchantJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("encode merchant response failed", "error", err)
+	}
+}
+
+func writeMerchantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrMerchantNotFound):
+		http.Error(w, "merchant not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		http.Error(w, "api key not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWithdrawalNotFound), errors.Is(err, service.ErrNotMerchantOwned):
+		// withdrawals of other merchants are not disclosed
+		http.Error(w, "withdrawal not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidScope):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrNoConsent):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		slog.Error("merchant request failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gophermart/internal/service"
+)
+
+const dateLayout = "2006-01-02"
+
+// parsePeriod reads the optional from/to query parameters. Both accept
+// RFC3339 or a plain date; a plain date in "to" includes that whole day.
+func parsePeriod(r *http.Request) (service.Period, error) {
+	var p service.Period
+	q := r.URL.Query()
+
+	if v := q.Get("from"); v != "" {
+		t, _, err := parseTimeParam(v)
+		if err != nil {
+			return p, fmt.Errorf("invalid from: %w", err)
+		}
+		p.From = t
+	}
+
+	if v := q.Get("to"); v != "" {
+		t, dateOnly, err := parseTimeParam(v)
+		if err != nil {
+			return p, fmt.Errorf("invalid to: %w", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		p.To = t
+	}
+
+	if !p.From.IsZero() && !p.To.IsZero() && !p.From.Before(p.To) {
+		return p, fmt.Errorf("from must be before to")
+	}
+
+	return p, nil
+}
+
+func parseTimeParam(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected RFC3339 or %s", dateLayout)
+	}
+	return t, true, nil
+}
+
+// idParam reads the UUID path parameter name. An ID that is not a UUID
+// can't name any record, so it is answered with 404 here instead of being
+// passed on to the database.
+func idParam(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	id := chi.URLParam(r, name)
+	if !isUUID(id) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return "", false
+	}
+	return id, true
+}
+
+// isUUID reports whether s is a UUID in its canonical 8-4-4-4-12 form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
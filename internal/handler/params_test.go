@@ -0,0 +1,25 @@
+package handler
+
+import "testing"
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", want: true},
+		{in: "3F2504E0-4F89-11D3-9A0C-0305E82C3301", want: true},
+		{in: "", want: false},
+		{in: "42", want: false},
+		{in: "3f2504e04f8911d39a0c0305e82c3301", want: false},
+		{in: "3f2504e0-4f89-11d3-9a0c-0305e82c330", want: false},
+		{in: "3f2504e0-4f89-11d3-9a0c-0305e82c3301a", want: false},
+		{in: "3f2504e0_4f89-11d3-9a0c-0305e82c3301", want: false},
+		{in: "3g2504e0-4f89-11d3-9a0c-0305e82c3301", want: false},
+	}
+	for _, tt := range tests {
+		if got := isUUID(tt.in); got != tt.want {
+			t.Errorf("isUUID(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+type cashoutRequest struct {
+	Sum         float64 `json:"sum"`
+	Destination string  `json:"destination"`
+}
+
+type payoutCallbackRequest struct {
+	Ref    string `json:"ref"`
+	Status string `json:"status"`
+}
+
+func CashoutHandler(payoutSvc *service.PayoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req cashoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Sum <= 0 || req.Destination == "" {
+			http.Error(w, "invalid sum or destination", http.StatusUnprocessableEntity)
+			return
+		}
+
+		withdrawal, err := payoutSvc.Cashout(r.Context(), userID, req.Sum, req.Destination)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		writeWithdrawal(w, http.StatusAccepted, withdrawal)
+	}
+}
+
+func PayoutCallbackHandler(payoutSvc *service.PayoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req payoutCallbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Ref == "" {
+			http.Error(w, "ref required", http.StatusBadRequest)
+			return
+		}
+
+		// intermediate statuses are polled anyway
+		if req.Status == service.PayoutPending {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if req.Status != service.PayoutSucceeded && req.Status != service.PayoutFailed {
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+
+		if err := payoutSvc.Apply(r.Context(), req.Ref, req.Status); err != nil {
+			switch {
+			case errors.Is(err, service.ErrPayoutNotFound):
+				http.Error(w, "payout not found", http.StatusNotFound)
+			default:
+				slog.Error("payout callback failed", "ref", req.Ref, "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
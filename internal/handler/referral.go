@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+func ListReferralsHandler(referralSvc *service.ReferralService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		referrals, err := referralSvc.ListByReferrer(r.Context(), userID)
+		if err != nil {
+			slog.Error("list referrals failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(referrals) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(referrals); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
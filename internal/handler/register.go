@@ -4,18 +4,17 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"gophermart/internal/service"
 )
 
 type registerRequest struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login        string `json:"login"`
+	Password     string `json:"password"`
+	ReferralCode string `json:"referral_code"`
 }
 
-func RegisterHandler(authSvc *service.AuthService, secret string) http.HandlerFunc {
+func RegisterHandler(authSvc *service.AuthService, tokenSvc *service.TokenService, cookies AuthCookies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -33,29 +32,25 @@ func RegisterHandler(authSvc *service.AuthService, secret string) http.HandlerFu
 			return
 		}
 
-		user, err := authSvc.Register(r.Context(), req.Login, req.Password)
+		user, err := authSvc.Register(r.Context(), req.Login, req.Password, req.ReferralCode)
 		if err != nil {
 			switch {
-			case errors.Is(err, errors.New("login already exists")):
+			case errors.Is(err, service.ErrLoginExists):
 				http.Error(w, "login already exists", http.StatusConflict)
+			case errors.Is(err, service.ErrInvalidReferralCode):
+				http.Error(w, "invalid referral code", http.StatusUnprocessableEntity)
 			default:
 				http.Error(w, "internal error", http.StatusInternalServerError)
 			}
 			return
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"user_id": user.ID,
-			"exp":     jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		})
-
-		tokenString, err := token.SignedString([]byte(secret))
+		pair, err := tokenSvc.Issue(r.Context(), user.ID, clientInfo(r))
 		if err != nil {
 			http.Error(w, "token generation failed", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Authorization", "Bearer "+tokenString)
-		w.WriteHeader(http.StatusOK)
+		writeTokens(w, pair, cookies)
 	}
 }
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/model"
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+func CreateRewardHandler(rewardSvc *service.RewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req := model.Reward{Active: true}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		reward, err := rewardSvc.Create(r.Context(), req)
+		if err != nil {
+			writeRewardError(w, err)
+			return
+		}
+
+		writeReward(w, http.StatusCreated, reward)
+	}
+}
+
+func UpdateRewardHandler(rewardSvc *service.RewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		// fields the body omits keep their stored values, so a PUT without
+		// "active" doesn't switch the reward off
+		current, err := rewardSvc.Get(r.Context(), id)
+		if err != nil {
+			writeRewardError(w, err)
+			return
+		}
+		req := *current
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		req.ID = current.ID
+
+		reward, err := rewardSvc.Update(r.Context(), req)
+		if err != nil {
+			writeRewardError(w, err)
+			return
+		}
+
+		writeReward(w, http.StatusOK, reward)
+	}
+}
+
+func GetRewardHandler(rewardSvc *service.RewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		reward, err := rewardSvc.Get(r.Context(), id)
+		if err != nil {
+			writeRewardError(w, err)
+			return
+		}
+
+		writeReward(w, http.StatusOK, reward)
+	}
+}
+
+// ListRewardsHandler serves the full catalog to admins and only what can be
+// redeemed right now to users.
+func ListRewardsHandler(rewardSvc *service.RewardService, availableOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rewards, err := rewardSvc.List(r.Context(), availableOnly)
+		if err != nil {
+			slog.Error("list rewards failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(rewards) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rewards); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func DeactivateRewardHandler(rewardSvc *service.RewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := rewardSvc.Deactivate(r.Context(), id); err != nil {
+			writeRewardError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func RedeemRewardHandler(rewardSvc *service.RewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		redemption, err := rewardSvc.Redeem(r.Context(), userID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrRewardNotFound), errors.Is(err, service.ErrRewardUnavailable):
+				writeRewardError(w, err)
+			default:
+				writeWithdrawalError(w, err)
+			}
+			return
+		}
+
+		status := http.StatusCreated
+		if redemption.Status == service.WithdrawalPendingApproval {
+			status = http.StatusAccepted
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(redemption); err != nil {
+			slog.Error("encode redemption failed", "error", err)
+		}
+	}
+}
+
+func ListRedemptionsHandler(rewardSvc *service.RewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		redemptions, err := rewardSvc.ListRedemptions(r.Context(), userID)
+		if err != nil {
+			slog.Error("list redemptions failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(redemptions) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(redemptions); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func writeReward(w http.ResponseWriter, status int, reward *model.Reward) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(reward); err != nil {
+		slog.Error("encode reward failed", "error", err)
+	}
+}
+
+func writeRewardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrRewardNotFound):
+		http.Error(w, "reward not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrRewardUnavailable):
+		http.Error(w, "reward is not available", http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidReward):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		slog.Error("reward request failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
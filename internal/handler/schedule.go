@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+type createScheduleRequest struct {
+	Reference string     `json:"reference"`
+	Sum       float64    `json:"sum"`
+	Period    string     `json:"period"`
+	StartAt   *time.Time `json:"start_at,omitempty"`
+}
+
+func CreateScheduleHandler(scheduleSvc *service.ScheduleService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req createScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Reference == "" || req.Sum <= 0 {
+			http.Error(w, "invalid reference or sum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		var startAt time.Time
+		if req.StartAt != nil {
+			startAt = *req.StartAt
+		}
+
+		schedule, err := scheduleSvc.Create(r.Context(), userID, req.Reference, req.Sum, req.Period, startAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidPeriod):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			default:
+				slog.Error("schedule create failed", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(schedule); err != nil {
+			slog.Error("encode schedule failed", "error", err)
+		}
+	}
+}
+
+func ListSchedulesHandler(scheduleSvc *service.ScheduleService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		schedules, err := scheduleSvc.ListByUser(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(schedules) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(schedules); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func DeleteScheduleHandler(scheduleSvc *service.ScheduleService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := scheduleSvc.Deactivate(r.Context(), userID, id); err != nil {
+			switch {
+			case errors.Is(err, service.ErrScheduleNotFound):
+				http.Error(w, "schedule not found", http.StatusNotFound)
+			default:
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListScheduleRunsHandler(scheduleSvc *service.ScheduleService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		runs, err := scheduleSvc.ListRuns(r.Context(), userID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrScheduleNotFound):
+				http.Error(w, "schedule not found", http.StatusNotFound)
+			default:
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if len(runs) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(runs); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+func ListSessionsHandler(tokenSvc *service.TokenService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+		jti := r.Context().Value(mw.TokenIDCtxKey).(string)
+
+		sessions, err := tokenSvc.ListSessions(r.Context(), userID, jti)
+		if err != nil {
+			slog.Error("list sessions failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(sessions) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sessions); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func TerminateSessionHandler(tokenSvc *service.TokenService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := tokenSvc.TerminateSession(r.Context(), userID, id); err != nil {
+			switch {
+			case errors.Is(err, service.ErrSessionNotFound):
+				http.Error(w, "session not found", http.StatusNotFound)
+			default:
+				slog.Error("terminate session failed", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
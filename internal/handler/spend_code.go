@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+type issueSpendCodeRequest struct {
+	Sum float64 `json:"sum"`
+}
+
+func IssueSpendCodeHandler(spendCodeSvc *service.SpendCodeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req issueSpendCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Sum <= 0 {
+			http.Error(w, "invalid sum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		code, err := spendCodeSvc.Issue(r.Context(), userID, req.Sum)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(code); err != nil {
+			slog.Error("encode spend code failed", "error", err)
+		}
+	}
+}
+
+type redeemSpendCodeRequest struct {
+	Code  string `json:"code"`
+	Order string `json:"order"`
+}
+
+func RedeemSpendCodeHandler(spendCodeSvc *service.SpendCodeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		merchantID := r.Context().Value(mw.MerchantCtxKey).(string)
+
+		var req redeemSpendCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Code == "" || req.Order == "" {
+			http.Error(w, "code and order required", http.StatusBadRequest)
+			return
+		}
+
+		if !validateLuhn(req.Order) {
+			http.Error(w, "invalid order number", http.StatusUnprocessableEntity)
+			return
+		}
+
+		withdrawal, err := spendCodeSvc.Redeem(r.Context(), merchantID, req.Code, req.Order)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrSpendCodeNotFound):
+				http.Error(w, "spend code not found", http.StatusNotFound)
+			case errors.Is(err, service.ErrSpendCodeUsed):
+				http.Error(w, "spend code already redeemed", http.StatusConflict)
+			case errors.Is(err, service.ErrSpendCodeExpired):
+				http.Error(w, "spend code expired or cancelled", http.StatusGone)
+			default:
+				writeWithdrawalError(w, err)
+			}
+			return
+		}
+
+		writeWithdrawal(w, http.StatusOK, withdrawal)
+	}
+}
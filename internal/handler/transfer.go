@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+type transferRequest struct {
+	To      string  `json:"to"`
+	Sum     float64 `json:"sum"`
+	Comment string  `json:"comment"`
+}
+
+func TransferHandler(transferSvc *service.TransferService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req transferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.To == "" {
+			http.Error(w, "recipient required", http.StatusBadRequest)
+			return
+		}
+		if req.Sum <= 0 {
+			http.Error(w, "invalid sum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		transfer, err := transferSvc.Transfer(r.Context(), userID, req.To, req.Sum, req.Comment)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInsufficientFunds):
+				http.Error(w, "insufficient funds", http.StatusPaymentRequired)
+			case errors.Is(err, service.ErrTransferLimit):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, service.ErrTransferRecipient):
+				http.Error(w, "recipient not found", http.StatusNotFound)
+			case errors.Is(err, service.ErrTransferSelf):
+				http.Error(w, "cannot transfer to yourself", http.StatusUnprocessableEntity)
+			case errors.Is(err, service.ErrTransferAmount):
+				http.Error(w, "invalid sum", http.StatusUnprocessableEntity)
+			default:
+				slog.Error("transfer failed", "error", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(transfer); err != nil {
+			slog.Error("encode transfer failed", "error", err)
+		}
+	}
+}
+
+func ListTransfersHandler(transferSvc *service.TransferService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		transfers, err := transferSvc.ListByUser(r.Context(), userID)
+		if err != nil {
+			slog.Error("list transfers failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(transfers) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(transfers); err != nil {
+			http.Error(w, "encode error", http.StatusInternalServerError)
+		}
+	}
+}
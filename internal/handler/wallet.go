@@ -0,0 +1,371 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"gophermart/internal/mw"
+	"gophermart/internal/service"
+)
+
+type createWalletRequest struct {
+	Name string `json:"name"`
+}
+
+func CreateWalletHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req createWalletRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+
+		wallet, err := walletSvc.Create(r.Context(), userID, req.Name)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		writeWalletJSON(w, http.StatusCreated, wallet)
+	}
+}
+
+func ListWalletsHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		wallets, err := walletSvc.ListByUser(r.Context(), userID)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		if len(wallets) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeWalletJSON(w, http.StatusOK, wallets)
+	}
+}
+
+func GetWalletHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		wallet, err := walletSvc.Get(r.Context(), userID, id)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		writeWalletJSON(w, http.StatusOK, wallet)
+	}
+}
+
+func GetWalletBalanceHandler(balanceSvc *service.BalanceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		balance, err := balanceSvc.GetWallet(r.Context(), userID, id)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		writeWalletJSON(w, http.StatusOK, balance)
+	}
+}
+
+func WalletWithdrawHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		req, ok := decodeWithdrawRequest(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		withdrawal, err := withdrawalSvc.CreateFromWallet(r.Context(), userID, id, req.Order, req.Sum)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		status := http.StatusOK
+		if withdrawal.Status == service.WithdrawalPendingApproval {
+			status = http.StatusAccepted
+		}
+		writeWithdrawal(w, status, withdrawal)
+	}
+}
+
+type walletInviteRequest struct {
+	Login string `json:"login"`
+}
+
+func InviteWalletMemberHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req walletInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.Login == "" {
+			http.Error(w, "login required", http.StatusBadRequest)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		invite, err := walletSvc.Invite(r.Context(), userID, id, req.Login)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		writeWalletJSON(w, http.StatusCreated, invite)
+	}
+}
+
+func ListWalletInvitesHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		invites, err := walletSvc.ListInvites(r.Context(), userID)
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		if len(invites) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeWalletJSON(w, http.StatusOK, invites)
+	}
+}
+
+func AcceptWalletInviteHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return respondWalletInviteHandler(walletSvc, true)
+}
+
+func DeclineWalletInviteHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return respondWalletInviteHandler(walletSvc, false)
+}
+
+func respondWalletInviteHandler(walletSvc *service.WalletService, accept bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := walletSvc.RespondInvite(r.Context(), userID, id, accept); err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type walletMemberRequest struct {
+	CanWithdraw   bool    `json:"can_withdraw"`
+	MaxWithdrawal float64 `json:"max_withdrawal"`
+}
+
+func UpdateWalletMemberHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req walletMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		if req.MaxWithdrawal < 0 {
+			http.Error(w, "invalid max_withdrawal", http.StatusUnprocessableEntity)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		err := walletSvc.UpdateMember(r.Context(), userID, id, chi.URLParam(r, "login"), service.WalletPermissions{
+			CanWithdraw:   req.CanWithdraw,
+			MaxWithdrawal: req.MaxWithdrawal,
+		})
+		if err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func RemoveWalletMemberHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := walletSvc.RemoveMember(r.Context(), userID, id, chi.URLParam(r, "login")); err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type walletRoutingRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+func SetWalletRoutingHandler(walletSvc *service.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		var req walletRoutingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := walletSvc.SetRouting(r.Context(), userID, id, req.Enabled); err != nil {
+			writeWalletError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeWalletError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrWalletNotFound):
+		http.Error(w, "wallet not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWalletInviteNotFound):
+		http.Error(w, "invite not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWalletMemberNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWalletForbidden):
+		http.Error(w, "only the wallet owner can do this", http.StatusForbidden)
+	case errors.Is(err, service.ErrWalletMemberExists), errors.Is(err, service.ErrWalletOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		slog.Error("wallet request failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func writeWalletJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("encode wallet response failed", "error", err)
+	}
+}
@@ -3,8 +3,11 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"gophermart/internal/model"
 	"gophermart/internal/mw"
 	"gophermart/internal/service"
 )
@@ -23,33 +26,152 @@ func WithdrawHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc
 
 		userID := r.Context().Value(mw.UserCtxKey).(string)
 
-		var req withdrawRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
+		req, ok := decodeWithdrawRequest(w, r)
+		if !ok {
 			return
 		}
 
-		if req.Order == "" || req.Sum <= 0 {
-			http.Error(w, "invalid order or sum", http.StatusUnprocessableEntity)
+		withdrawal, err := withdrawalSvc.Create(r.Context(), userID, req.Order, req.Sum)
+		if err != nil {
+			writeWithdrawalError(w, err)
 			return
 		}
 
-		if !validateLuhn(req.Order) {
-			http.Error(w, "invalid order number", http.StatusUnprocessableEntity)
+		if withdrawal.Status == service.WithdrawalPendingApproval {
+			writeWithdrawal(w, http.StatusAccepted, withdrawal)
 			return
 		}
 
-		if err := withdrawalSvc.Create(r.Context(), userID, req.Order, req.Sum); err != nil {
-			switch {
-			case errors.Is(err, errors.New("insufficient funds")):
-				http.Error(w, "insufficient funds", http.StatusPaymentRequired)
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-			}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func ReserveHandler(withdrawalSvc *service.WithdrawalService, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		req, ok := decodeWithdrawRequest(w, r)
+		if !ok {
+			return
+		}
+
+		withdrawal, err := withdrawalSvc.Reserve(r.Context(), userID, req.Order, req.Sum, ttl)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		writeWithdrawal(w, http.StatusCreated, withdrawal)
+	}
+}
+
+func ConfirmWithdrawalHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		withdrawal, err := withdrawalSvc.Confirm(r.Context(), userID, id)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		if withdrawal.Status == service.WithdrawalPendingApproval {
+			writeWithdrawal(w, http.StatusAccepted, withdrawal)
+			return
+		}
+
+		writeWithdrawal(w, http.StatusOK, withdrawal)
+	}
+}
+
+func CancelWithdrawalHandler(withdrawalSvc *service.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(mw.UserCtxKey).(string)
+
+		id, ok := idParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		withdrawal, err := withdrawalSvc.Cancel(r.Context(), userID, id)
+		if err != nil {
+			writeWithdrawalError(w, err)
+			return
+		}
+
+		writeWithdrawal(w, http.StatusOK, withdrawal)
+	}
+}
+
+func decodeWithdrawRequest(w http.ResponseWriter, r *http.Request) (withdrawRequest, bool) {
+	var req withdrawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return req, false
+	}
+
+	if req.Order == "" || req.Sum <= 0 {
+		http.Error(w, "invalid order or sum", http.StatusUnprocessableEntity)
+		return req, false
+	}
+
+	if !validateLuhn(req.Order) {
+		http.Error(w, "invalid order number", http.StatusUnprocessableEntity)
+		return req, false
+	}
+
+	return req, true
+}
+
+func writeWithdrawalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInsufficientFunds):
+		http.Error(w, "insufficient funds", http.StatusPaymentRequired)
+	case errors.Is(err, service.ErrWithdrawalLimit):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrWithdrawalOrderUsed), errors.Is(err, service.ErrWithdrawalOrderForeign):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrWithdrawalNotFound):
+		http.Error(w, "withdrawal not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWithdrawalState):
+		http.Error(w, "withdrawal is not in a suitable state", http.StatusConflict)
+	case errors.Is(err, service.ErrHoldExpired):
+		http.Error(w, "withdrawal hold expired", http.StatusGone)
+	case errors.Is(err, service.ErrWalletNotFound):
+		http.Error(w, "wallet not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWalletForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		slog.Error("withdrawal failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func writeWithdrawal(w http.ResponseWriter, status int, withdrawal *model.Withdrawal) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(withdrawal); err != nil {
+		slog.Error("encode withdrawal failed", "error", err)
 	}
 }
 
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type Campaign struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Kind           string    `json:"kind"` // MULTIPLIER, FIXED
+	Value          float64   `json:"value"`
+	StartsAt       time.Time `json:"starts_at"`
+	EndsAt         time.Time `json:"ends_at"`
+	MinTier        string    `json:"min_tier,omitempty"`
+	FirstOrderOnly bool      `json:"first_order_only"`
+	MinOrders      int       `json:"min_orders,omitempty"`
+	MaxOrders      int       `json:"max_orders,omitempty"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type Merchant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MerchantAPIKey struct {
+	ID         string     `json:"id"`
+	MerchantID string     `json:"merchant_id"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"` // only returned when the key is issued
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type MerchantConsent struct {
+	MerchantID   string    `json:"merchant_id"`
+	MerchantName string    `json:"merchant_name"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MerchantSigningSecret is returned once when a signing secret is set.
+type MerchantSigningSecret struct {
+	MerchantID string `json:"merchant_id"`
+	Secret     string `json:"secret"`
+	Required   bool   `json:"required"`
+}
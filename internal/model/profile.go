@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type Profile struct {
+	Login             string    `json:"login"`
+	CreatedAt         time.Time `json:"created_at"`
+	ReferralCode      string    `json:"referral_code"`
+	Tier              string    `json:"tier"`
+	TierScore         float64   `json:"tier_score"`
+	Multiplier        float64   `json:"multiplier"`
+	NextTier          string    `json:"next_tier,omitempty"`
+	NextTierThreshold float64   `json:"next_tier_threshold,omitempty"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type Referral struct {
+	RefereeLogin string     `json:"referee"`
+	Status       string     `json:"status"`
+	Reason       string     `json:"reason,omitempty"`
+	Bonus        float64    `json:"bonus,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RewardedAt   *time.Time `json:"rewarded_at,omitempty"`
+}
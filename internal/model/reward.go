@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type Reward struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Price       float64    `json:"price"`
+	Stock       *int       `json:"stock,omitempty"` // nil means unlimited
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type Redemption struct {
+	ID           string    `json:"id"`
+	RewardID     string    `json:"reward_id"`
+	RewardName   string    `json:"reward_name"`
+	WithdrawalID string    `json:"withdrawal_id"`
+	Code         string    `json:"code"`
+	Price        float64   `json:"price"`
+	Status       string    `json:"status"` // status of the backing withdrawal
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+type WithdrawalSchedule struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Reference  string     `json:"reference"`
+	Sum        float64    `json:"sum"`
+	Period     string     `json:"period"` // daily, weekly, monthly or a duration such as 72h
+	NextRunAt  time.Time  `json:"next_run_at"`
+	Active     bool       `json:"active"`
+	Failures   int        `json:"failures"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastStatus string     `json:"last_status,omitempty"`
+	AnchorDay  int        `json:"-"` // day of the month monthly runs fall on, 0 for legacy schedules
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ScheduleRun struct {
+	ID           string    `json:"id"`
+	ScheduleID   string    `json:"schedule_id"`
+	Status       string    `json:"status"` // SUCCEEDED, FAILED, PENDING_APPROVAL
+	WithdrawalID string    `json:"withdrawal_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	RanAt        time.Time `json:"ran_at"`
+}
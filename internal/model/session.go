@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// Session is one login of a user: the refresh token family started by it
+// and the access tokens issued from that family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type SpendCode struct {
+	Code         string     `json:"code"`
+	QRPayload    string     `json:"qr_payload"`
+	WithdrawalID string     `json:"withdrawal_id"`
+	Sum          float64    `json:"sum"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RedeemedAt   *time.Time `json:"redeemed_at,omitempty"`
+	OrderNumber  string     `json:"order,omitempty"`
+}
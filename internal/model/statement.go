@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type StatementEntry struct {
+	Kind       string    `json:"kind"` // ACCRUAL, WITHDRAWAL, RELEASE, REVERSAL, EXPIRY, *_BONUS, TRANSFER_IN, TRANSFER_OUT
+	Reference  string    `json:"reference"`
+	Amount     float64   `json:"amount"`
+	Balance    float64   `json:"balance"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type Statement struct {
+	From           *time.Time       `json:"from,omitempty"`
+	To             *time.Time       `json:"to,omitempty"`
+	OpeningBalance float64          `json:"opening_balance"`
+	ClosingBalance float64          `json:"closing_balance"`
+	Entries        []StatementEntry `json:"entries"`
+}
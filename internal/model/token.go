@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// TokenPair is returned on login, registration and refresh. The access
+// token is also sent in the Authorization header.
+type TokenPair struct {
+	AccessToken      string    `json:"access_token,omitempty"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type Transfer struct {
+	ID           string    `json:"id"`
+	Direction    string    `json:"direction"` // IN, OUT
+	Counterparty string    `json:"counterparty"`
+	Amount       float64   `json:"amount"`
+	Comment      string    `json:"comment,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -6,5 +6,6 @@ type User struct {
 	ID           string    `json:"id"`
 	Login        string    `json:"login"`
 	PasswordHash []byte    `json:"-"`
+	ReferralCode string    `json:"referral_code"`
 	CreatedAt    time.Time `json:"created_at"`
 }
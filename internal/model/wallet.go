@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+type Wallet struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Role      string         `json:"role"` // OWNER, MEMBER
+	Current   float64        `json:"current"`
+	Withdrawn float64        `json:"withdrawn"`
+	Held      float64        `json:"held,omitempty"`
+	Routed    bool           `json:"routed"`
+	CreatedAt time.Time      `json:"created_at"`
+	Members   []WalletMember `json:"members,omitempty"`
+}
+
+type WalletMember struct {
+	Login         string    `json:"login"`
+	Role          string    `json:"role"`
+	CanWithdraw   bool      `json:"can_withdraw"`
+	MaxWithdrawal float64   `json:"max_withdrawal,omitempty"`
+	RouteAccruals bool      `json:"route_accruals"`
+	JoinedAt      time.Time `json:"joined_at"`
+}
+
+type WalletInvite struct {
+	ID         string    `json:"id"`
+	WalletID   string    `json:"wallet_id"`
+	WalletName string    `json:"wallet_name"`
+	From       string    `json:"from"`
+	Status     string    `json:"status"` // PENDING, ACCEPTED, DECLINED
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -3,9 +3,26 @@ package model
 import "time"
 
 type Withdrawal struct {
-	ID          string    `json:"id"`
-	UserID      string    `json:"user_id"`
-	OrderNumber string    `json:"order"`
-	Sum         float64   `json:"sum"`
-	ProcessedAt time.Time `json:"processed_at"`
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	WalletID       string     `json:"wallet_id,omitempty"`
+	OrderNumber    string     `json:"order"`
+	Sum            float64    `json:"sum"`
+	Status         string     `json:"status"` // RESERVED, CONFIRMED, CANCELLED, EXPIRED, PENDING_APPROVAL, REJECTED
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Refunded       float64    `json:"refunded,omitempty"`
+	ReversalStatus string     `json:"reversal_status,omitempty"` // PARTIALLY_REVERSED, REVERSED
+	ReviewReason   string     `json:"review_reason,omitempty"`
+	Type           string     `json:"type"` // ORDER, CASHOUT, REWARD, IN_STORE
+	Destination    string     `json:"destination,omitempty"`
+	PayoutStatus   string     `json:"payout_status,omitempty"` // NEW, SUBMITTING, PENDING, SUCCEEDED, FAILED
+	ProcessedAt    time.Time  `json:"processed_at"`
+}
+
+type WithdrawalReversal struct {
+	ID           string    `json:"id"`
+	WithdrawalID string    `json:"withdrawal_id"`
+	Sum          float64   `json:"sum"`
+	Reason       string    `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
 }
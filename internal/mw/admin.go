@@ -0,0 +1,27 @@
+package mw
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const AdminTokenHeader = "X-Admin-Token"
+
+func AdminMiddleware(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminToken == "" {
+				http.Error(w, "admin api disabled", http.StatusForbidden)
+				return
+			}
+
+			token := r.Header.Get(AdminTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package mw
 
 import (
 	"context"
-	"errors"
 	"net/http"
 	"strings"
 
@@ -11,31 +10,51 @@ import (
 
 type contextKey string
 
-const UserCtxKey contextKey = "user_id"
+const (
+	UserCtxKey    contextKey = "user_id"
+	TokenIDCtxKey contextKey = "token_id"
+)
+
+// TokenRevocationChecker reports whether an access token ID was revoked,
+// either by itself or by terminating its session.
+type TokenRevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
 
-func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// TokenKeys resolves the verification key of a token by its kid header.
+type TokenKeys interface {
+	Keyfunc(token *jwt.Token) (any, error)
+	Methods() []string
+}
+
+// AuthMiddleware accepts access tokens signed by one of keys that carry a
+// token ID (jti) which has not been revoked. The token is read from the
+// Authorization header or, for browser clients, from the access cookie;
+// cookie-authenticated mutating requests need a CSRF token.
+func AuthMiddleware(keys TokenKeys, revocations TokenRevocationChecker) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			var tokenString string
+			if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+				parts := strings.Split(authHeader, " ")
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					http.Error(w, "invalid token format", http.StatusUnauthorized)
+					return
+				}
+				tokenString = parts[1]
+			} else if cookie, err := r.Cookie(AccessCookieName); err == nil && cookie.Value != "" {
+				// browsers send cookies on cross-site requests too
+				if !ValidCSRF(r) {
+					http.Error(w, "invalid csrf token", http.StatusForbidden)
+					return
+				}
+				tokenString = cookie.Value
+			} else {
 				http.Error(w, "unauthorized", http.StatusUnauthorized)
 				return
 			}
 
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "invalid token format", http.StatusUnauthorized)
-				return
-			}
-
-			tokenString := parts[1]
-
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, errors.New("unexpected signing method")
-				}
-				return []byte(jwtSecret), nil
-			})
+			token, err := jwt.Parse(tokenString, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
 
 			if err != nil || !token.Valid {
 				http.Error(w, "invalid or expired token", http.StatusUnauthorized)
@@ -54,7 +73,24 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
+			jti, ok := claims["jti"].(string)
+			if !ok || jti == "" {
+				http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			revoked, err := revocations.IsRevoked(r.Context(), jti)
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "token revoked", http.StatusUnauthorized)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), UserCtxKey, userID)
+			ctx = context.WithValue(ctx, TokenIDCtxKey, jti)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
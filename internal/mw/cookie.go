@@ -0,0 +1,34 @@
+package mw
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const (
+	AccessCookieName  = "gophermart_access"
+	RefreshCookieName = "gophermart_refresh"
+	CSRFCookieName    = "gophermart_csrf"
+	CSRFHeader        = "X-CSRF-Token"
+
+	// RefreshCookiePath limits the refresh token cookie to the refresh
+	// endpoint so it is not sent with every request.
+	RefreshCookiePath = "/api/user/token/refresh"
+)
+
+// ValidCSRF implements the double-submit check: the CSRF header must repeat
+// the CSRF cookie, which only scripts of our own origin can read. Safe
+// methods pass without a token.
+func ValidCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeader)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
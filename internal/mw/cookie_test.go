@@ -0,0 +1,39 @@
+package mw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidCSRF(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		want   bool
+	}{
+		{name: "safe method without token", method: http.MethodGet, want: true},
+		{name: "head without token", method: http.MethodHead, want: true},
+		{name: "matching token", method: http.MethodPost, cookie: "abc", header: "abc", want: true},
+		{name: "no cookie", method: http.MethodPost, header: "abc", want: false},
+		{name: "no header", method: http.MethodPost, cookie: "abc", want: false},
+		{name: "empty cookie and header", method: http.MethodDelete, cookie: "", header: "", want: false},
+		{name: "different token", method: http.MethodPut, cookie: "abc", header: "abd", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/user/orders", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFHeader, tt.header)
+			}
+			if got := ValidCSRF(r); got != tt.want {
+				t.Errorf("ValidCSRF() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
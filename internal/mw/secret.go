@@ -0,0 +1,26 @@
+package mw
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// SharedSecretMiddleware guards server-to-server endpoints with a static
+// secret sent in header. An empty secret disables the endpoints.
+func SharedSecretMiddleware(header, secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				http.Error(w, "endpoint disabled", http.StatusForbidden)
+				return
+			}
+
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(secret)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
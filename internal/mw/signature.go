@@ -0,0 +1,172 @@
+package mw
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	ContentSHA256Header      = "X-Content-SHA256"
+
+	maxSignedBody = 1 << 20
+)
+
+// SigningSecretFunc returns the HMAC secret for the caller of r and whether
+// the caller must sign. A nil secret means the caller has none configured.
+type SigningSecretFunc func(r *http.Request) (secret []byte, required bool, err error)
+
+// StaticSigningSecret always requires signatures with secret. An empty
+// secret disables the endpoints, like SharedSecretMiddleware.
+func StaticSigningSecret(secret string) SigningSecretFunc {
+	return func(r *http.Request) ([]byte, bool, error) {
+		return []byte(secret), true, nil
+	}
+}
+
+// NonceCache remembers nonces of accepted requests for ttl so a captured
+// request can't be replayed while its timestamp is still fresh.
+type NonceCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// Add records nonce and reports whether it was not seen before.
+func (c *NonceCache) Add(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > c.ttl {
+		for n, at := range c.seen {
+			if now.Sub(at) > c.ttl {
+				delete(c.seen, n)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if at, ok := c.seen[nonce]; ok && now.Sub(at) <= c.ttl {
+		return false
+	}
+	c.seen[nonce] = now
+	return true
+}
+
+// CanonicalRequest builds the string that is signed:
+//
+//	METHOD\nPATH\nSORTED_QUERY\nTIMESTAMP\nNONCE\nHEX(SHA256(BODY))
+func CanonicalRequest(method, path, rawQuery, timestamp, nonce, bodyHash string) string {
+	params := strings.Split(rawQuery, "&")
+	sort.Strings(params)
+	query := strings.Trim(strings.Join(params, "&"), "&")
+
+	return strings.Join([]string{method, path, query, timestamp, nonce, bodyHash}, "\n")
+}
+
+// Sign returns the hex HMAC-SHA256 of canonical under secret.
+func Sign(secret []byte, canonical string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureMiddleware verifies signed requests. Callers without a secret
+// may send unsigned requests; callers whose secret is required may not.
+// Timestamps are unix seconds and must be within maxSkew of the server
+// clock; nonces are rejected when seen again within that window.
+func SignatureMiddleware(secretFn SigningSecretFunc, nonces *NonceCache, maxSkew time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, required, err := secretFn(r)
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+
+			signature := r.Header.Get(SignatureHeader)
+			if signature == "" {
+				if required {
+					if len(secret) == 0 {
+						http.Error(w, "endpoint disabled", http.StatusForbidden)
+						return
+					}
+					http.Error(w, "signature required", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(secret) == 0 {
+				http.Error(w, "no signing secret configured", http.StatusUnauthorized)
+				return
+			}
+
+			timestamp := r.Header.Get(SignatureTimestampHeader)
+			nonce := r.Header.Get(SignatureNonceHeader)
+			if timestamp == "" || nonce == "" {
+				http.Error(w, "signature timestamp and nonce required", http.StatusUnauthorized)
+				return
+			}
+
+			sec, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid signature timestamp", http.StatusUnauthorized)
+				return
+			}
+			now := time.Now()
+			if skew := now.Sub(time.Unix(sec, 0)); skew > maxSkew || skew < -maxSkew {
+				http.Error(w, "signature timestamp out of range", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+			if err != nil {
+				http.Error(w, "read body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxSignedBody {
+				http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.Sum256(body)
+			bodyHash := hex.EncodeToString(sum[:])
+			if h := r.Header.Get(ContentSHA256Header); h != "" && !strings.EqualFold(h, bodyHash) {
+				http.Error(w, "body hash mismatch", http.StatusUnauthorized)
+				return
+			}
+
+			canonical := CanonicalRequest(r.Method, r.URL.EscapedPath(), r.URL.RawQuery, timestamp, nonce, bodyHash)
+			if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(Sign(secret, canonical))) {
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+
+			// only signatures that verified use up a nonce
+			if !nonces.Add(nonce, now) {
+				http.Error(w, "nonce already used", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
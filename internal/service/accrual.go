@@ -57,3 +57,21 @@ func (c *AccrualClient) GetOrder(ctx context.Context, number string) (*AccrualRe
 		return nil, fmt.Errorf("unexpected status: %d, body: %s", resp.StatusCode, string(body))
 	}
 }
+
+// OrderUpdate maps the accrual system's answer to the status and accrual
+// stored on the order. PROCESSED without points is stored as INVALID.
+func (r AccrualResponse) OrderUpdate() (string, *float64) {
+	switch r.Status {
+	case "PROCESSED":
+		if r.Accrual > 0 {
+			accrual := r.Accrual
+			return "PROCESSED", &accrual
+		}
+		return "INVALID", nil
+	case "INVALID":
+		return "INVALID", nil
+	case "REGISTERED", "PROCESSING":
+		return "PROCESSING", nil
+	}
+	return r.Status, nil
+}
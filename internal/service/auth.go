@@ -6,7 +6,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -21,24 +20,68 @@ func NewAuthService(db *sql.DB) *AuthService {
 	return &AuthService{db: db}
 }
 
-func (s *AuthService) Register(ctx context.Context, login, password string) (*model.User, error) {
+var (
+	ErrLoginExists        = errors.New("login already exists")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+)
+
+// Register creates a user. A non-empty referralCode links the new user to
+// its owner and must belong to an existing user.
+func (s *AuthService) Register(ctx context.Context, login, password, referralCode string) (*model.User, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, fmt.Errorf("hash password: %w", err)
 	}
 
-	query := `INSERT INTO users (login, password_hash) VALUES ($1, $2) RETURNING id, login, created_at`
-	row := s.db.QueryRowContext(ctx, query, login, hash)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
 
+	// referral codes are short, so a new one can collide with an existing
+	// code; skip the conflict and try another instead of failing the signup
+	query := `
+		INSERT INTO users (login, password_hash, referral_code) VALUES ($1, $2, $3)
+		ON CONFLICT (referral_code) DO NOTHING
+		RETURNING id, login, referral_code, created_at
+	`
 	var user model.User
-	if err := row.Scan(&user.ID, &user.Login, &user.CreatedAt); err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
-			return nil, errors.New("login already exists")
+	for attempt := 0; ; attempt++ {
+		if attempt == referralCodeAttempts {
+			return nil, errors.New("generate referral code: no free code found")
+		}
+
+		code, err := newReferralCode()
+		if err != nil {
+			return nil, fmt.Errorf("generate referral code: %w", err)
+		}
+
+		row := tx.QueryRowContext(ctx, query, login, hash, code)
+		err = row.Scan(&user.ID, &user.Login, &user.ReferralCode, &user.CreatedAt)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if isUniqueViolation(err, "users_login_key") {
+			return nil, ErrLoginExists
 		}
 		return nil, fmt.Errorf("insert user: %w", err)
 	}
 	user.PasswordHash = hash
 
+	if referralCode != "" {
+		if err := attachReferral(ctx, tx, user.ID, referralCode); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
 	return &user, nil
 }
 
@@ -49,13 +92,13 @@ func (s *AuthService) Authenticate(ctx context.Context, login, password string)
 	var user model.User
 	if err := row.Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("invalid login or password")
+			return nil, ErrInvalidCredentials
 		}
 		return nil, fmt.Errorf("get user: %w", err)
 	}
 
 	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
-		return nil, errors.New("invalid login or password")
+		return nil, ErrInvalidCredentials
 	}
 
 	return &user, nil
@@ -5,32 +5,204 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"gophermart/internal/model"
 )
 
 type BalanceService struct {
-	db *sql.DB
+	db        *sql.DB
+	limits    WithdrawalLimits
+	expirySvc *ExpiryService
 }
 
-func NewBalanceService(db *sql.DB) *BalanceService {
-	return &BalanceService{db: db}
+func NewBalanceService(db *sql.DB, limits WithdrawalLimits, expirySvc *ExpiryService) *BalanceService {
+	return &BalanceService{db: db, limits: limits, expirySvc: expirySvc}
 }
 
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   float64              `json:"current"`
+	Withdrawn float64              `json:"withdrawn"`
+	Held      float64              `json:"held,omitempty"`
+	Pending   *PendingAccrual      `json:"pending,omitempty"`
+	Allowance *WithdrawalAllowance `json:"allowance,omitempty"`
+	Expiring  *ExpiringPoints      `json:"expiring,omitempty"`
+}
+
+// PendingAccrual describes orders the accrual system has not settled yet.
+// Expected only covers orders whose accrual is already known.
+type PendingAccrual struct {
+	Count    int                      `json:"count"`
+	Expected float64                  `json:"expected,omitempty"`
+	ByStatus map[string]StatusSummary `json:"by_status"`
+}
+
+type StatusSummary struct {
+	Count   int     `json:"count"`
+	Accrual float64 `json:"accrual"`
 }
 
 func (s *BalanceService) Get(ctx context.Context, userID string) (*Balance, error) {
 	var b Balance
 	err := s.db.QueryRowContext(ctx,
-		`SELECT COALESCE(current_balance, 0), COALESCE(withdrawn, 0) FROM users WHERE id = $1`,
+		`SELECT COALESCE(current_balance, 0), COALESCE(withdrawn, 0), held FROM users WHERE id = $1`,
 		userID,
-	).Scan(&b.Current, &b.Withdrawn)
+	).Scan(&b.Current, &b.Withdrawn, &b.Held)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
 		}
 		return nil, fmt.Errorf("get balance: %w", err)
 	}
+
+	if s.limits.enabled() {
+		b.Allowance, err = s.limits.allowance(ctx, s.db, userID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.Expiring, err = s.expirySvc.NextExpiring(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetWallet returns a shared wallet's balance to one of its members.
+func (s *BalanceService) GetWallet(ctx context.Context, userID, walletID string) (*Balance, error) {
+	if _, err := walletRole(ctx, s.db, walletID, userID); err != nil {
+		return nil, err
+	}
+
+	var b Balance
+	err := s.db.QueryRowContext(ctx,
+		`SELECT current_balance, withdrawn, held FROM wallets WHERE id = $1`,
+		walletID,
+	).Scan(&b.Current, &b.Withdrawn, &b.Held)
+	if err != nil {
+		return nil, fmt.Errorf("get wallet balance: %w", err)
+	}
+	return &b, nil
+}
+
+func (s *BalanceService) GetAt(ctx context.Context, userID string, at time.Time) (*Balance, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+
+	var b Balance
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM (`+ledgerSQL+`) l WHERE user_id = $1 AND occurred_at <= $2`,
+		userID, at,
+	).Scan(&b.Current)
+	if err != nil {
+		return nil, fmt.Errorf("get balance at: %w", err)
+	}
+
+	// only confirmed withdrawals count as withdrawn, reserved ones are held
+	err = s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COALESCE(SUM(sum), 0) FROM withdrawals
+			 WHERE user_id = $1 AND wallet_id IS NULL AND status = 'CONFIRMED' AND processed_at <= $2)
+			-
+			(SELECT COALESCE(SUM(r.sum), 0) FROM withdrawal_reversals r
+			 JOIN withdrawals w ON w.id = r.withdrawal_id
+			 WHERE r.user_id = $1 AND w.wallet_id IS NULL AND r.created_at <= $2)
+	`, userID, at).Scan(&b.Withdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("get withdrawn at: %w", err)
+	}
 	return &b, nil
 }
+
+func (s *BalanceService) Pending(ctx context.Context, userID string) (*PendingAccrual, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT status, COUNT(*), COALESCE(SUM(accrual), 0)
+		FROM orders
+		WHERE user_id = $1
+		GROUP BY status
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query order summary: %w", err)
+	}
+	defer rows.Close()
+
+	p := &PendingAccrual{ByStatus: map[string]StatusSummary{}}
+	for rows.Next() {
+		var status string
+		var sum StatusSummary
+		if err := rows.Scan(&status, &sum.Count, &sum.Accrual); err != nil {
+			return nil, fmt.Errorf("scan order summary: %w", err)
+		}
+		p.ByStatus[status] = sum
+		if status == "NEW" || status == "PROCESSING" {
+			p.Count += sum.Count
+			p.Expected += sum.Accrual
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return p, nil
+}
+
+func (s *BalanceService) Statement(ctx context.Context, userID string, period Period) (*model.Statement, error) {
+	from, to := period.args()
+
+	st := &model.Statement{Entries: []model.StatementEntry{}}
+	if !period.From.IsZero() {
+		st.From = &period.From
+	}
+	if !period.To.IsZero() {
+		st.To = &period.To
+	}
+
+	if from != nil {
+		err := s.db.QueryRowContext(ctx,
+			`SELECT COALESCE(SUM(amount), 0) FROM (`+ledgerSQL+`) l WHERE user_id = $1 AND occurred_at < $2`,
+			userID, from,
+		).Scan(&st.OpeningBalance)
+		if err != nil {
+			return nil, fmt.Errorf("opening balance: %w", err)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT kind, reference, amount, occurred_at
+		FROM (`+ledgerSQL+`) l
+		WHERE user_id = $1
+		  AND ($2::timestamptz IS NULL OR occurred_at >= $2)
+		  AND ($3::timestamptz IS NULL OR occurred_at < $3)
+		ORDER BY occurred_at ASC, kind ASC
+	`, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query ledger: %w", err)
+	}
+	defer rows.Close()
+
+	running := st.OpeningBalance
+	for rows.Next() {
+		var e model.StatementEntry
+		if err := rows.Scan(&e.Kind, &e.Reference, &e.Amount, &e.OccurredAt); err != nil {
+			return nil, fmt.Errorf("scan ledger entry: %w", err)
+		}
+		running += e.Amount
+		e.Balance = running
+		st.Entries = append(st.Entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	st.ClosingBalance = running
+
+	return st, nil
+}
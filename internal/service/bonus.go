@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+const BonusTier = "TIER_BONUS"
+
+// bonus is a credit on top of an order's accrual. Bonuses are stored
+// separately so the accrual reported by the accrual system stays traceable.
+type bonus struct {
+	UserID      string
+	Kind        string
+	OrderNumber string
+	CampaignID  string
+	Amount      float64
+}
+
+func creditBonus(ctx context.Context, tx *sql.Tx, b bonus) error {
+	b.Amount = roundPoints(b.Amount)
+	if b.Amount <= 0 {
+		return nil
+	}
+
+	var campaignID any
+	if b.CampaignID != "" {
+		campaignID = b.CampaignID
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO bonuses (user_id, kind, order_number, campaign_id, amount) VALUES ($1, $2, $3, $4, $5)`,
+		b.UserID, b.Kind, b.OrderNumber, campaignID, b.Amount,
+	)
+	if err != nil {
+		return fmt.Errorf("insert bonus: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET current_balance = current_balance + $1 WHERE id = $2`, b.Amount, b.UserID)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+
+	return creditLot(ctx, tx, b.UserID, "bonus:"+b.Kind, b.Amount)
+}
+
+func roundPoints(v float64) float64 {
+	return math.Round(v*100) / 100
+}
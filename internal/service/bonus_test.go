@@ -0,0 +1,22 @@
+package service
+
+import "testing"
+
+func TestRoundPoints(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{in: 0, want: 0},
+		{in: 0.001, want: 0},
+		{in: 0.005, want: 0.01},
+		{in: 1.234, want: 1.23},
+		{in: 1.235, want: 1.24},
+		{in: 10.1 * 3, want: 30.3},
+		{in: -2.345, want: -2.35},
+	}
+	for _, tt := range tests {
+		if got := roundPoints(tt.in); got != tt.want {
+			t.Errorf("roundPoints(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	CampaignMultiplier = "MULTIPLIER"
+	CampaignFixed      = "FIXED"
+
+	BonusCampaign = "CAMPAIGN_BONUS"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+)
+
+const campaignColumns = `id, name, kind, value, starts_at, ends_at, min_tier, first_order_only, min_orders, max_orders, active, created_at`
+
+// CampaignService manages time-boxed promotions and credits their bonuses
+// when an order is processed. MULTIPLIER campaigns add accrual*(value-1),
+// FIXED campaigns add value. Order counts include the order being processed.
+type CampaignService struct {
+	db      *sql.DB
+	tierSvc *TierService
+}
+
+func NewCampaignService(db *sql.DB, tierSvc *TierService) *CampaignService {
+	return &CampaignService{db: db, tierSvc: tierSvc}
+}
+
+func scanCampaign(row interface{ Scan(...any) error }) (model.Campaign, error) {
+	var c model.Campaign
+	err := row.Scan(&c.ID, &c.Name, &c.Kind, &c.Value, &c.StartsAt, &c.EndsAt, &c.MinTier,
+		&c.FirstOrderOnly, &c.MinOrders, &c.MaxOrders, &c.Active, &c.CreatedAt)
+	return c, err
+}
+
+func (s *CampaignService) validate(c model.Campaign) error {
+	switch {
+	case c.Name == "":
+		return fmt.Errorf("%w: name required", ErrInvalidCampaign)
+	case c.Kind != CampaignMultiplier && c.Kind != CampaignFixed:
+		return fmt.Errorf("%w: kind must be MULTIPLIER or FIXED", ErrInvalidCampaign)
+	case c.Kind == CampaignMultiplier && c.Value <= 1:
+		return fmt.Errorf("%w: multiplier must be greater than 1", ErrInvalidCampaign)
+	case c.Kind == CampaignFixed && c.Value <= 0:
+		return fmt.Errorf("%w: fixed bonus must be positive", ErrInvalidCampaign)
+	case !c.StartsAt.Before(c.EndsAt):
+		return fmt.Errorf("%w: starts_at must be before ends_at", ErrInvalidCampaign)
+	case c.MinOrders < 0 || c.MaxOrders < 0 || (c.MaxOrders > 0 && c.MaxOrders < c.MinOrders):
+		return fmt.Errorf("%w: invalid order count range", ErrInvalidCampaign)
+	case c.MinTier != "" && s.tierSvc.byName(c.MinTier).Name != c.MinTier:
+		return fmt.Errorf("%w: unknown tier %s", ErrInvalidCampaign, c.MinTier)
+	}
+	return nil
+}
+
+func (s *CampaignService) Create(ctx context.Context, c model.Campaign) (*model.Campaign, error) {
+	if err := s.validate(c); err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO campaigns (name, kind, value, starts_at, ends_at, min_tier, first_order_only, min_orders, max_orders, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+campaignColumns,
+		c.Name, c.Kind, c.Value, c.StartsAt, c.EndsAt, c.MinTier, c.FirstOrderOnly, c.MinOrders, c.MaxOrders, c.Active,
+	)
+	created, err := scanCampaign(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert campaign: %w", err)
+	}
+	return &created, nil
+}
+
+func (s *CampaignService) Update(ctx context.Context, c model.Campaign) (*model.Campaign, error) {
+	if err := s.validate(c); err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE campaigns
+		SET name = $2, kind = $3, value = $4, starts_at = $5, ends_at = $6, min_tier = $7,
+		    first_order_only = $8, min_orders = $9, max_orders = $10, active = $11
+		WHERE id = $1
+		RETURNING `+campaignColumns,
+		c.ID, c.Name, c.Kind, c.Value, c.StartsAt, c.EndsAt, c.MinTier, c.FirstOrderOnly, c.MinOrders, c.MaxOrders, c.Active,
+	)
+	updated, err := scanCampaign(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("update campaign: %w", err)
+	}
+	return &updated, nil
+}
+
+func (s *CampaignService) Get(ctx context.Context, id string) (*model.Campaign, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns WHERE id = $1`, id)
+	c, err := scanCampaign(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("get campaign: %w", err)
+	}
+	return &c, nil
+}
+
+func (s *CampaignService) List(ctx context.Context) ([]model.Campaign, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+campaignColumns+` FROM campaigns ORDER BY starts_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("query campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns := []model.Campaign{}
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return campaigns, nil
+}
+
+// Deactivate switches a campaign off. Campaigns are never deleted so the
+// bonuses they produced stay traceable.
+func (s *CampaignService) Deactivate(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE campaigns SET active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrCampaignNotFound
+		}
+		return fmt.Errorf("deactivate campaign: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCampaignNotFound
+	}
+	return nil
+}
+
+func (s *CampaignService) OnAccrual(ctx context.Context, tx *sql.Tx, ev AccrualEvent) error {
+	now := time.Now()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+campaignColumns+` FROM campaigns
+		WHERE active AND starts_at <= $1 AND ends_at > $1
+	`, now)
+	if err != nil {
+		return fmt.Errorf("query campaigns: %w", err)
+	}
+	var campaigns []model.Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, c)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration failed: %w", err)
+	}
+	if len(campaigns) == 0 {
+		return nil
+	}
+
+	var tierName string
+	var processed int
+	err = tx.QueryRowContext(ctx, `
+		SELECT u.tier, (SELECT COUNT(*) FROM orders o WHERE o.user_id = u.id AND o.status = 'PROCESSED')
+		FROM users u WHERE u.id = $1
+	`, ev.UserID).Scan(&tierName, &processed)
+	if err != nil {
+		return fmt.Errorf("get campaign eligibility: %w", err)
+	}
+	tier := s.tierSvc.byName(tierName)
+
+	for _, c := range campaigns {
+		if c.MinTier != "" && tier.Threshold < s.tierSvc.byName(c.MinTier).Threshold {
+			continue
+		}
+		if c.FirstOrderOnly && processed != 1 {
+			continue
+		}
+		if processed < c.MinOrders || (c.MaxOrders > 0 && processed > c.MaxOrders) {
+			continue
+		}
+
+		amount := c.Value
+		if c.Kind == CampaignMultiplier {
+			amount = ev.Accrual * (c.Value - 1)
+		}
+
+		err := creditBonus(ctx, tx, bonus{
+			UserID:      ev.UserID,
+			Kind:        BonusCampaign,
+			OrderNumber: ev.OrderNumber,
+			CampaignID:  c.ID,
+			Amount:      amount,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ExpiringPoints is the earliest upcoming expiry of a user's points.
+type ExpiringPoints struct {
+	Sum float64   `json:"sum"`
+	At  time.Time `json:"at"`
+}
+
+// ExpiryService expires accrual lots ttl after they were credited. A zero
+// ttl disables expiration.
+type ExpiryService struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+func NewExpiryService(db *sql.DB, ttl time.Duration) *ExpiryService {
+	return &ExpiryService{db: db, ttl: ttl}
+}
+
+func (s *ExpiryService) Enabled() bool {
+	return s.ttl > 0
+}
+
+// ExpiryCursor marks the last lot an ExpireDue batch looked at. Passing it
+// to the next call moves past lots that failed instead of retrying them for
+// the rest of the run.
+type ExpiryCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// ExpireDue expires up to limit lots whose lifetime ended before now,
+// starting after the cursor (nil for the first batch). It returns how many
+// lots were expired and the cursor of the next batch, which is nil once no
+// more lots are due. A lot that fails is skipped and its error joined into
+// the returned one.
+func (s *ExpiryService) ExpireDue(ctx context.Context, now time.Time, after *ExpiryCursor, limit int) (int, *ExpiryCursor, error) {
+	if !s.Enabled() {
+		return 0, nil, nil
+	}
+
+	cursor := ExpiryCursor{ID: "00000000-0000-0000-0000-000000000000"}
+	if after != nil {
+		cursor = *after
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, created_at FROM accrual_lots
+		WHERE remaining > 0 AND created_at <= $1
+		  AND (created_at, id) > ($2, $3::uuid)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $4
+	`, now.Add(-s.ttl), cursor.CreatedAt, cursor.ID, limit)
+	if err != nil {
+		return 0, nil, fmt.Errorf("query expired lots: %w", err)
+	}
+
+	type lot struct {
+		id, userID string
+		createdAt  time.Time
+	}
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.userID, &l.createdAt); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("scan lot: %w", err)
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	expired := 0
+	var errs []error
+	for _, l := range lots {
+		ok, err := s.expireLot(ctx, l.userID, l.id, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("expire lot %s: %w", l.id, err))
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	var next *ExpiryCursor
+	if len(lots) == limit {
+		last := lots[len(lots)-1]
+		next = &ExpiryCursor{CreatedAt: last.createdAt, ID: last.id}
+	}
+
+	return expired, next, errors.Join(errs...)
+}
+
+func (s *ExpiryService) expireLot(ctx context.Context, userID, lotID string, now time.Time) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// same lock order as withdrawals: user first, then lots
+	var current float64
+	err = tx.QueryRowContext(ctx, `SELECT current_balance FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&current)
+	if err != nil {
+		return false, fmt.Errorf("get balance: %w", err)
+	}
+
+	var remaining float64
+	err = tx.QueryRowContext(ctx, `SELECT remaining FROM accrual_lots WHERE id = $1 FOR UPDATE`, lotID).Scan(&remaining)
+	if err != nil {
+		return false, fmt.Errorf("get lot: %w", err)
+	}
+	// consumed by a withdrawal since it was selected
+	if remaining <= 0 {
+		return false, nil
+	}
+
+	amount := min(remaining, current)
+
+	_, err = tx.ExecContext(ctx, `UPDATE accrual_lots SET remaining = 0, expired_at = $1 WHERE id = $2`, now, lotID)
+	if err != nil {
+		return false, fmt.Errorf("update lot: %w", err)
+	}
+
+	if amount > 0 {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO point_expiries (user_id, lot_id, sum, expired_at) VALUES ($1, $2, $3, $4)`,
+			userID, lotID, amount, now,
+		)
+		if err != nil {
+			return false, fmt.Errorf("insert expiry: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE users SET current_balance = current_balance - $1 WHERE id = $2`, amount, userID)
+		if err != nil {
+			return false, fmt.Errorf("update balance: %w", err)
+		}
+
+		if err := notify(ctx, tx, userID, "POINTS_EXPIRED", fmt.Sprintf("%.2f points expired", amount)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// NextExpiring returns the points that expire first, summed over all lots
+// expiring on that same day, or nil when nothing is due to expire.
+func (s *ExpiryService) NextExpiring(ctx context.Context, userID string) (*ExpiringPoints, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	var first sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT MIN(created_at) FROM accrual_lots WHERE user_id = $1 AND remaining > 0`,
+		userID,
+	).Scan(&first)
+	if err != nil {
+		return nil, fmt.Errorf("get earliest lot: %w", err)
+	}
+	if !first.Valid {
+		return nil, nil
+	}
+
+	at := first.Time.Add(s.ttl)
+	dayEnd := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location()).AddDate(0, 0, 1)
+
+	p := &ExpiringPoints{At: at}
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(remaining), 0) FROM accrual_lots WHERE user_id = $1 AND remaining > 0 AND created_at < $2`,
+		userID, dayEnd.Add(-s.ttl),
+	).Scan(&p.Sum)
+	if err != nil {
+		return nil, fmt.Errorf("sum expiring lots: %w", err)
+	}
+
+	return p, nil
+}
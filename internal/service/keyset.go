@@ -0,0 +1,169 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"gophermart/internal/model"
+)
+
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// SigningKey is one entry of a Keyset. Keys loaded from a public key file
+// can only verify.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   any
+	verify any
+}
+
+// Keyset signs access tokens with its first key and verifies them with any
+// of its keys, picked by the token's kid header. To rotate, put the new key
+// first and keep the old one listed for at least the access token TTL.
+type Keyset struct {
+	keys []*SigningKey
+}
+
+// LoadKeyset reads keys in the KID:ALG:PATH[,...] format, e.g.
+// "2025b:EdDSA:/etc/gophermart/2025b.pem,2025a:RS256:/etc/gophermart/2025a.pem".
+// ALG is HS256 (the file holds the raw secret), RS256 or EdDSA (PEM).
+func LoadKeyset(spec string) (*Keyset, error) {
+	var ks Keyset
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), ":", 3)
+		if len(fields) != 3 || fields[0] == "" || fields[2] == "" {
+			return nil, fmt.Errorf("invalid jwt key %q", part)
+		}
+
+		data, err := os.ReadFile(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("read jwt key %s: %w", fields[0], err)
+		}
+
+		key, err := parseSigningKey(fields[0], fields[1], data)
+		if err != nil {
+			return nil, err
+		}
+		if ks.key(key.ID) != nil {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		ks.keys = append(ks.keys, key)
+	}
+
+	if len(ks.keys) == 0 || ks.keys[0].sign == nil {
+		return nil, errors.New("the first jwt key must be a private key")
+	}
+	return &ks, nil
+}
+
+// NewHMACKeyset returns a keyset with a single HS256 key.
+func NewHMACKeyset(kid string, secret []byte) (*Keyset, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty jwt secret")
+	}
+	return &Keyset{keys: []*SigningKey{{ID: kid, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}}}, nil
+}
+
+func parseSigningKey(kid, alg string, data []byte) (*SigningKey, error) {
+	key := SigningKey{ID: kid}
+	var err error
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("empty jwt secret %s", kid)
+		}
+		key.Method, key.sign, key.verify = jwt.SigningMethodHS256, secret, secret
+	case jwt.SigningMethodRS256.Alg():
+		key.Method = jwt.SigningMethodRS256
+		if priv, perr := jwt.ParseRSAPrivateKeyFromPEM(data); perr == nil {
+			key.sign, key.verify = priv, &priv.PublicKey
+		} else {
+			key.verify, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		}
+	case jwt.SigningMethodEdDSA.Alg():
+		key.Method = jwt.SigningMethodEdDSA
+		if priv, perr := jwt.ParseEdPrivateKeyFromPEM(data); perr == nil {
+			key.sign, key.verify = priv, priv.(ed25519.PrivateKey).Public()
+		} else {
+			key.verify, err = jwt.ParseEdPublicKeyFromPEM(data)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse jwt key %s: %w", kid, err)
+	}
+	return &key, nil
+}
+
+func (k *Keyset) key(kid string) *SigningKey {
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Sign signs claims with the current key and sets the kid header.
+func (k *Keyset) Sign(claims jwt.Claims) (string, error) {
+	key := k.keys[0]
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.sign)
+}
+
+// Keyfunc implements mw.TokenKeys. The token's algorithm has to match the
+// one of the key named by kid.
+func (k *Keyset) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := k.key(kid)
+	if key == nil {
+		return nil, ErrUnknownSigningKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verify, nil
+}
+
+// Methods implements mw.TokenKeys.
+func (k *Keyset) Methods() []string {
+	var methods []string
+	for _, key := range k.keys {
+		methods = append(methods, key.Method.Alg())
+	}
+	return methods
+}
+
+// JWKS returns the public keys of the set. HMAC keys are never published.
+func (k *Keyset) JWKS() model.JWKS {
+	jwks := model.JWKS{Keys: []model.JWK{}}
+	for _, key := range k.keys {
+		jwk := model.JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
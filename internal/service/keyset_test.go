@@ -0,0 +1,102 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() jwt.Claims {
+	return jwt.RegisteredClaims{Subject: "user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestKeysetSignAndVerify(t *testing.T) {
+	ks, err := NewHMACKeyset("k1", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := ks.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(signed, ks.Keyfunc, jwt.WithValidMethods(ks.Methods()))
+	if err != nil || !token.Valid {
+		t.Fatalf("parse own token: %v", err)
+	}
+	if kid := token.Header["kid"]; kid != "k1" {
+		t.Errorf("kid = %v, want k1", kid)
+	}
+}
+
+func TestKeysetKeyfuncUnknownKid(t *testing.T) {
+	signer, _ := NewHMACKeyset("old", []byte("secret"))
+	verifier, _ := NewHMACKeyset("new", []byte("secret"))
+
+	signed, err := signer.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(signed, verifier.Keyfunc)
+	if !errors.Is(err, ErrUnknownSigningKey) {
+		t.Errorf("parse = %v, want ErrUnknownSigningKey", err)
+	}
+}
+
+// A token must not be accepted under a different algorithm than its key's,
+// e.g. an HS256 token keyed by the published public key of an EdDSA kid.
+func TestKeysetKeyfuncAlgMismatch(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseSigningKey("ed", jwt.SigningMethodEdDSA.Alg(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := &Keyset{keys: []*SigningKey{key}}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = "ed"
+	signed, err := forged.SignedString([]byte(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.Keyfunc(forged); err == nil {
+		t.Error("Keyfunc accepted an HS256 token for an EdDSA key")
+	}
+	if _, err := jwt.Parse(signed, ks.Keyfunc); err == nil {
+		t.Error("parse accepted an HS256 token for an EdDSA key")
+	}
+
+	genuine, err := ks.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(genuine, ks.Keyfunc); err != nil {
+		t.Errorf("parse EdDSA token: %v", err)
+	}
+}
+
+func TestParseSigningKeyRejects(t *testing.T) {
+	if _, err := parseSigningKey("k", "HS512", []byte("secret")); err == nil {
+		t.Error("unsupported algorithm accepted")
+	}
+	if _, err := parseSigningKey("k", "HS256", []byte("  \n")); err == nil {
+		t.Error("empty HMAC secret accepted")
+	}
+	if _, err := NewHMACKeyset("k", nil); err == nil {
+		t.Error("empty keyset secret accepted")
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+// ledgerSQL lists every movement of every user's spendable balance as
+// (user_id, kind, reference, amount, occurred_at). Credits are positive,
+// debits negative, so SUM(amount) over a user's rows is their balance.
+// A withdrawal debits when it is created, even if only reserved; a released
+// hold shows up as a separate RELEASE credit. Accruals routed to a shared
+// wallet and withdrawals drawn from one never touch the user's balance.
+const ledgerSQL = `
+	SELECT user_id, 'ACCRUAL' AS kind, number AS reference, accrual AS amount, COALESCE(accrued_at, uploaded_at) AS occurred_at
+	FROM orders
+	WHERE status = 'PROCESSED' AND accrual > 0 AND wallet_id IS NULL
+	UNION ALL
+	SELECT user_id, 'WITHDRAWAL', order_number, -sum, created_at
+	FROM withdrawals
+	WHERE wallet_id IS NULL
+	UNION ALL
+	SELECT user_id, 'RELEASE', order_number, sum, released_at
+	FROM withdrawals
+	WHERE released_at IS NOT NULL AND wallet_id IS NULL
+	UNION ALL
+	SELECT r.user_id, 'REVERSAL', w.order_number, r.sum, r.created_at
+	FROM withdrawal_reversals r
+	JOIN withdrawals w ON w.id = r.withdrawal_id
+	WHERE w.wallet_id IS NULL
+	UNION ALL
+	SELECT user_id, 'EXPIRY', lot_id::text, -sum, expired_at
+	FROM point_expiries
+	UNION ALL
+	SELECT user_id, kind, order_number, amount, created_at
+	FROM bonuses
+	UNION ALL
+	SELECT from_user_id, 'TRANSFER_OUT', id::text, -amount, created_at
+	FROM transfers
+	UNION ALL
+	SELECT to_user_id, 'TRANSFER_IN', id::text, amount, created_at
+	FROM transfers
+`
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrWithdrawalLimit = errors.New("withdrawal limit exceeded")
+
+// WithdrawalLimits are applied to every user's withdrawals. Zero values
+// disable the corresponding rule. Daily and monthly caps follow UTC
+// calendar days and months and count reserved, pending and confirmed
+// withdrawals.
+type WithdrawalLimits struct {
+	MinAmount     float64
+	MaxSingle     float64
+	DailyCap      float64
+	MonthlyCap    float64
+	MinAccountAge time.Duration
+}
+
+// WithdrawalAllowance is what a user may still withdraw under the limits.
+// Nil remaining amounts mean the cap is not configured.
+type WithdrawalAllowance struct {
+	MinAmount        float64    `json:"min_amount,omitempty"`
+	MaxSingle        float64    `json:"max_single,omitempty"`
+	DailyRemaining   *float64   `json:"daily_remaining,omitempty"`
+	MonthlyRemaining *float64   `json:"monthly_remaining,omitempty"`
+	AvailableFrom    *time.Time `json:"available_from,omitempty"`
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (l WithdrawalLimits) enabled() bool {
+	return l != WithdrawalLimits{}
+}
+
+// check must run in the transaction that holds the user's row lock so
+// concurrent withdrawals cannot both fit under the same cap.
+func (l WithdrawalLimits) check(ctx context.Context, q queryer, userID string, sum float64, now time.Time) error {
+	if !l.enabled() {
+		return nil
+	}
+	if l.MinAmount > 0 && sum < l.MinAmount {
+		return fmt.Errorf("%w: minimum withdrawal is %.2f", ErrWithdrawalLimit, l.MinAmount)
+	}
+	if l.MaxSingle > 0 && sum > l.MaxSingle {
+		return fmt.Errorf("%w: maximum single withdrawal is %.2f", ErrWithdrawalLimit, l.MaxSingle)
+	}
+
+	a, err := l.allowance(ctx, q, userID, now)
+	if err != nil {
+		return err
+	}
+	if a.AvailableFrom != nil {
+		return fmt.Errorf("%w: withdrawals available from %s", ErrWithdrawalLimit, a.AvailableFrom.Format(time.RFC3339))
+	}
+	if a.DailyRemaining != nil && sum > *a.DailyRemaining {
+		return fmt.Errorf("%w: daily remaining is %.2f", ErrWithdrawalLimit, *a.DailyRemaining)
+	}
+	if a.MonthlyRemaining != nil && sum > *a.MonthlyRemaining {
+		return fmt.Errorf("%w: monthly remaining is %.2f", ErrWithdrawalLimit, *a.MonthlyRemaining)
+	}
+	return nil
+}
+
+func (l WithdrawalLimits) allowance(ctx context.Context, q queryer, userID string, now time.Time) (*WithdrawalAllowance, error) {
+	a := &WithdrawalAllowance{MinAmount: l.MinAmount, MaxSingle: l.MaxSingle}
+
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var createdAt time.Time
+	var daily, monthly float64
+	err := q.QueryRowContext(ctx, `
+		SELECT u.created_at,
+		       COALESCE(SUM(w.sum) FILTER (WHERE w.created_at >= $2), 0),
+		       COALESCE(SUM(w.sum) FILTER (WHERE w.created_at >= $3), 0)
+		FROM users u
+		LEFT JOIN withdrawals w
+		       ON w.user_id = u.id
+		      AND w.status IN ('RESERVED', 'CONFIRMED', 'PENDING_APPROVAL')
+		      AND w.created_at >= LEAST($2, $3)
+		WHERE u.id = $1
+		GROUP BY u.created_at
+	`, userID, dayStart, monthStart).Scan(&createdAt, &daily, &monthly)
+	if err != nil {
+		return nil, fmt.Errorf("get withdrawal totals: %w", err)
+	}
+
+	if l.MinAccountAge > 0 {
+		if from := createdAt.Add(l.MinAccountAge); from.After(now) {
+			a.AvailableFrom = &from
+		}
+	}
+	if l.DailyCap > 0 {
+		a.DailyRemaining = remaining(l.DailyCap, daily)
+	}
+	if l.MonthlyCap > 0 {
+		a.MonthlyRemaining = remaining(l.MonthlyCap, monthly)
+	}
+
+	return a, nil
+}
+
+func remaining(limit, used float64) *float64 {
+	r := limit - used
+	if r < 0 {
+		r = 0
+	}
+	return &r
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRemaining(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit, used float64
+		want        float64
+	}{
+		{name: "unused", limit: 100, used: 0, want: 100},
+		{name: "partly used", limit: 100, used: 40, want: 60},
+		{name: "used up", limit: 100, used: 100, want: 0},
+		{name: "over the cap", limit: 100, used: 130, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := *remaining(tt.limit, tt.used); got != tt.want {
+				t.Errorf("remaining(%v, %v) = %v, want %v", tt.limit, tt.used, got, tt.want)
+			}
+		})
+	}
+}
+
+// The amount rules are checked before any totals are queried, so these
+// cases need no database.
+func TestWithdrawalLimitsCheckAmount(t *testing.T) {
+	limits := WithdrawalLimits{MinAmount: 10, MaxSingle: 500}
+	now := time.Now()
+
+	for _, sum := range []float64{0.01, 9.99, 500.01, 1000} {
+		err := limits.check(context.Background(), nil, "user", sum, now)
+		if !errors.Is(err, ErrWithdrawalLimit) {
+			t.Errorf("check(%v) = %v, want ErrWithdrawalLimit", sum, err)
+		}
+	}
+}
+
+func TestWithdrawalLimitsDisabled(t *testing.T) {
+	var limits WithdrawalLimits
+	if limits.enabled() {
+		t.Fatal("zero limits are enabled")
+	}
+	if err := limits.check(context.Background(), nil, "user", 1e9, time.Now()); err != nil {
+		t.Errorf("check with no limits = %v, want nil", err)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Every credit to a user's balance is recorded as an accrual lot so points
+// can expire individually. Withdrawals consume lots oldest first and
+// remember what they took, so a released hold or a refund puts points back
+// into the lots they came from instead of granting them a fresh lifetime.
+// Balances that predate lots are turned into one "migrated" lot per user by
+// a one-off backfill at startup (see database.InitSchema). Points that are
+// still uncovered never expire, and withdrawals take from lots only what
+// the lots hold.
+
+func creditLot(ctx context.Context, tx *sql.Tx, userID, source string, amount float64) error {
+	if amount <= 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO accrual_lots (user_id, source, amount, remaining) VALUES ($1, $2, $3, $3)`,
+		userID, source, amount,
+	)
+	if err != nil {
+		return fmt.Errorf("insert accrual lot: %w", err)
+	}
+	return nil
+}
+
+type lotTake struct {
+	lotID  string
+	amount float64
+}
+
+// takeLots removes sum from the user's lots oldest first and reports what
+// it took. The caller must hold the user's row lock.
+func takeLots(ctx context.Context, tx *sql.Tx, userID string, sum float64) ([]lotTake, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, remaining FROM accrual_lots
+		WHERE user_id = $1 AND remaining > 0
+		ORDER BY created_at ASC, id ASC
+		FOR UPDATE
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query lots: %w", err)
+	}
+
+	var takes []lotTake
+	left := sum
+	for rows.Next() && left > 0 {
+		var id string
+		var remaining float64
+		if err := rows.Scan(&id, &remaining); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan lot: %w", err)
+		}
+		amount := min(remaining, left)
+		takes = append(takes, lotTake{lotID: id, amount: amount})
+		left -= amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	for _, t := range takes {
+		if _, err := tx.ExecContext(ctx, `UPDATE accrual_lots SET remaining = remaining - $1 WHERE id = $2`, t.amount, t.lotID); err != nil {
+			return nil, fmt.Errorf("update lot: %w", err)
+		}
+	}
+
+	return takes, nil
+}
+
+// moveLots takes sum out of the sender's lots and credits the recipient
+// with lots of the same age, so transferred points keep the expiry they had.
+// Whatever the sender's lots did not cover stays uncovered for the recipient
+// too. The caller must hold both users' row locks.
+func moveLots(ctx context.Context, tx *sql.Tx, fromUserID, toUserID, source string, sum float64) error {
+	takes, err := takeLots(ctx, tx, fromUserID, sum)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range takes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO accrual_lots (user_id, source, amount, remaining, created_at)
+			SELECT $1, $2, $3, $3, created_at FROM accrual_lots WHERE id = $4
+		`, toUserID, source, t.amount, t.lotID)
+		if err != nil {
+			return fmt.Errorf("insert accrual lot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// consumeLots takes sum out of the user's lots for a withdrawal. The caller
+// must hold the user's row lock.
+func consumeLots(ctx context.Context, tx *sql.Tx, userID, withdrawalID string, sum float64) error {
+	takes, err := takeLots(ctx, tx, userID, sum)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range takes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO lot_consumptions (lot_id, withdrawal_id, amount) VALUES ($1, $2, $3)`,
+			t.lotID, withdrawalID, t.amount,
+		)
+		if err != nil {
+			return fmt.Errorf("insert lot consumption: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restoreLots gives sum of a withdrawal back to the lots it consumed, most
+// recent lots first. Whatever was not taken from a lot becomes a new lot.
+func restoreLots(ctx context.Context, tx *sql.Tx, userID, withdrawalID string, sum float64) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.id, c.lot_id, c.amount - c.restored
+		FROM lot_consumptions c
+		JOIN accrual_lots l ON l.id = c.lot_id
+		WHERE c.withdrawal_id = $1 AND c.amount > c.restored
+		ORDER BY l.created_at DESC, l.id DESC
+		FOR UPDATE OF c
+	`, withdrawalID)
+	if err != nil {
+		return fmt.Errorf("query lot consumptions: %w", err)
+	}
+
+	type give struct {
+		consumptionID string
+		lotID         string
+		amount        float64
+	}
+	var gives []give
+	left := sum
+	for rows.Next() && left > 0 {
+		var g give
+		var open float64
+		if err := rows.Scan(&g.consumptionID, &g.lotID, &open); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan lot consumption: %w", err)
+		}
+		g.amount = min(open, left)
+		gives = append(gives, g)
+		left -= g.amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	for _, g := range gives {
+		if _, err := tx.ExecContext(ctx, `UPDATE lot_consumptions SET restored = restored + $1 WHERE id = $2`, g.amount, g.consumptionID); err != nil {
+			return fmt.Errorf("update lot consumption: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE accrual_lots SET remaining = remaining + $1 WHERE id = $2`, g.amount, g.lotID); err != nil {
+			return fmt.Errorf("update lot: %w", err)
+		}
+	}
+
+	return creditLot(ctx, tx, userID, "refund:"+withdrawalID, left)
+}
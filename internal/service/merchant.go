@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	ScopeOrdersWrite      = "orders:write"
+	ScopeBalanceRead      = "balance:read"
+	ScopeWithdrawalRefund = "withdrawals:refund"
+	ScopeSpendCodesRedeem = "spend-codes:redeem"
+
+	apiKeyPrefix = "gm_"
+)
+
+var MerchantScopes = []string{ScopeOrdersWrite, ScopeBalanceRead, ScopeWithdrawalRefund, ScopeSpendCodesRedeem}
+
+var (
+	ErrMerchantNotFound = errors.New("merchant not found")
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrInvalidAPIKey    = errors.New("invalid api key")
+	ErrInvalidScope     = errors.New("invalid scope")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrNoConsent        = errors.New("user has not granted consent to this merchant")
+	ErrNotMerchantOwned = errors.New("withdrawal was not made with this merchant")
+)
+
+// MerchantService manages partner merchants and their API keys. Keys are
+// shown once when issued and stored only as SHA-256 hashes; the prefix is
+// kept in clear text to find the key row. Rotating a key issues a new one
+// with the same scopes and lets the old one work for rotationGrace.
+type MerchantService struct {
+	db            *sql.DB
+	rotationGrace time.Duration
+}
+
+func NewMerchantService(db *sql.DB, rotationGrace time.Duration) *MerchantService {
+	return &MerchantService{db: db, rotationGrace: rotationGrace}
+}
+
+func (s *MerchantService) Create(ctx context.Context, name string) (*model.Merchant, error) {
+	m := model.Merchant{Name: name, Active: true}
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO merchants (name) VALUES ($1) RETURNING id, created_at`,
+		name,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert merchant: %w", err)
+	}
+	return &m, nil
+}
+
+func (s *MerchantService) List(ctx context.Context) ([]model.Merchant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, active, created_at FROM merchants ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("query merchants: %w", err)
+	}
+	defer rows.Close()
+
+	var merchants []model.Merchant
+	for rows.Next() {
+		var m model.Merchant
+		if err := rows.Scan(&m.ID, &m.Name, &m.Active, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan merchant: %w", err)
+		}
+		merchants = append(merchants, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return merchants, nil
+}
+
+func (s *MerchantService) IssueKey(ctx context.Context, merchantID string, scopes []string) (*model.MerchantAPIKey, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(MerchantScopes, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope required", ErrInvalidScope)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	key, err := s.issueKey(ctx, tx, merchantID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *MerchantService) issueKey(ctx context.Context, tx *sql.Tx, merchantID string, scopes []string) (*model.MerchantAPIKey, error) {
+	prefix, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate key prefix: %w", err)
+	}
+	secret, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	prefix = prefix[:12]
+
+	key := model.MerchantAPIKey{
+		MerchantID: merchantID,
+		Prefix:     prefix,
+		Key:        apiKeyPrefix + prefix + "_" + secret,
+		Scopes:     scopes,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO merchant_api_keys (merchant_id, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, merchantID, prefix, hashAPIKey(key.Key), strings.Join(scopes, " ")).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err) || isInvalidText(err) {
+			return nil, ErrMerchantNotFound
+		}
+		return nil, fmt.Errorf("insert api key: %w", err)
+	}
+	return &key, nil
+}
+
+func (s *MerchantService) ListKeys(ctx context.Context, merchantID string) ([]model.MerchantAPIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, merchant_id, prefix, scopes, created_at, expires_at, revoked_at, last_used_at
+		FROM merchant_api_keys
+		WHERE merchant_id = $1
+		ORDER BY created_at DESC
+	`, merchantID)
+	if err != nil {
+		if isInvalidText(err) {
+			return nil, ErrMerchantNotFound
+		}
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []model.MerchantAPIKey
+	for rows.Next() {
+		var k model.MerchantAPIKey
+		var scopes string
+		var expiresAt, revokedAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&k.ID, &k.MerchantID, &k.Prefix, &scopes, &k.CreatedAt, &expiresAt, &revokedAt, &lastUsedAt); err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		k.Scopes = strings.Fields(scopes)
+		if expiresAt.Valid {
+			k.ExpiresAt = &expiresAt.Time
+		}
+		if revokedAt.Valid {
+			k.RevokedAt = &revokedAt.Time
+		}
+		if lastUsedAt.Valid {
+			k.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RotateKey issues a replacement for keyID and lets the old key expire
+// after the rotation grace period.
+func (s *MerchantService) RotateKey(ctx context.Context, merchantID, keyID string) (*model.MerchantAPIKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var scopes string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE merchant_api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $3), $3)
+		WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+		RETURNING scopes
+	`, keyID, merchantID, time.Now().Add(s.rotationGrace)).Scan(&scopes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("expire api key: %w", err)
+	}
+
+	key, err := s.issueKey(ctx, tx, merchantID, strings.Fields(scopes))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *MerchantService) RevokeKey(ctx context.Context, merchantID, keyID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE merchant_api_keys SET revoked_at = NOW() WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL`,
+		keyID, merchantID,
+	)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// VerifyKey implements mw.MerchantKeyVerifier. last_used_at is written at
+// most once a minute per key.
+func (s *MerchantService) VerifyKey(ctx context.Context, key string) (string, []string, error) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", nil, ErrInvalidAPIKey
+	}
+
+	var id, merchantID, hash, scopes string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT k.id, k.merchant_id, k.key_hash, k.scopes
+		FROM merchant_api_keys k
+		JOIN merchants m ON m.id = k.merchant_id
+		WHERE k.prefix = $1 AND k.revoked_at IS NULL
+		  AND (k.expires_at IS NULL OR k.expires_at > NOW())
+		  AND m.active
+	`, prefix).Scan(&id, &merchantID, &hash, &scopes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil, ErrInvalidAPIKey
+		}
+		return "", nil, fmt.Errorf("get api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(key))) != 1 {
+		return "", nil, ErrInvalidAPIKey
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE merchant_api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, id)
+	if err != nil {
+		return "", nil, fmt.Errorf("update api key usage: %w", err)
+	}
+
+	return merchantID, strings.Fields(scopes), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetSigningSecret generates a new request signing secret for the merchant,
+// replacing any previous one. When required is set, unsigned requests are
+// rejected. Unlike API keys the secret is kept in clear text because the
+// server has to compute the same HMAC.
+func (s *MerchantService) SetSigningSecret(ctx context.Context, merchantID string, required bool) (*model.MerchantSigningSecret, error) {
+	a, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate signing secret: %w", err)
+	}
+	b, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate signing secret: %w", err)
+	}
+
+	secret := model.MerchantSigningSecret{MerchantID: merchantID, Secret: a + b, Required: required}
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE merchants SET signing_secret = $1, signing_required = $2 WHERE id = $3`,
+		secret.Secret, required, merchantID,
+	)
+	if err != nil {
+		if isInvalidText(err) {
+			return nil, ErrMerchantNotFound
+		}
+		return nil, fmt.Errorf("set signing secret: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrMerchantNotFound
+	}
+	return &secret, nil
+}
+
+// ClearSigningSecret turns request signing off for the merchant.
+func (s *MerchantService) ClearSigningSecret(ctx context.Context, merchantID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE merchants SET signing_secret = NULL, signing_required = FALSE WHERE id = $1`,
+		merchantID,
+	)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrMerchantNotFound
+		}
+		return fmt.Errorf("clear signing secret: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMerchantNotFound
+	}
+	return nil
+}
+
+// SigningSecret implements mw.MerchantSecretSource.
+func (s *MerchantService) SigningSecret(ctx context.Context, merchantID string) (string, bool, error) {
+	var secret sql.NullString
+	var required bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT signing_secret, signing_required FROM merchants WHERE id = $1`,
+		merchantID,
+	).Scan(&secret, &required)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, ErrMerchantNotFound
+		}
+		return "", false, fmt.Errorf("get signing secret: %w", err)
+	}
+	return secret.String, required, nil
+}
+
+// UserIDByLogin resolves the user a merchant acts for.
+func (s *MerchantService) UserIDByLogin(ctx context.Context, login string) (string, error) {
+	var userID string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM users WHERE login = $1`, login).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("get user: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *MerchantService) GrantConsent(ctx context.Context, userID, merchantID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO merchant_consents (user_id, merchant_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, merchant_id) DO NOTHING
+	`, userID, merchantID)
+	if err != nil {
+		if isForeignKeyViolation(err) || isInvalidText(err) {
+			return ErrMerchantNotFound
+		}
+		return fmt.Errorf("insert consent: %w", err)
+	}
+	return nil
+}
+
+func (s *MerchantService) RevokeConsent(ctx context.Context, userID, merchantID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM merchant_consents WHERE user_id = $1 AND merchant_id = $2`,
+		userID, merchantID,
+	)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrMerchantNotFound
+		}
+		return fmt.Errorf("delete consent: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMerchantNotFound
+	}
+	return nil
+}
+
+func (s *MerchantService) ListConsents(ctx context.Context, userID string) ([]model.MerchantConsent, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.merchant_id, m.name, c.created_at
+		FROM merchant_consents c
+		JOIN merchants m ON m.id = c.merchant_id
+		WHERE c.user_id = $1
+		ORDER BY c.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query consents: %w", err)
+	}
+	defer rows.Close()
+
+	var consents []model.MerchantConsent
+	for rows.Next() {
+		var c model.MerchantConsent
+		if err := rows.Scan(&c.MerchantID, &c.MerchantName, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan consent: %w", err)
+		}
+		consents = append(consents, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return consents, nil
+}
+
+func (s *MerchantService) RequireConsent(ctx context.Context, userID, merchantID string) error {
+	var granted bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM merchant_consents WHERE user_id = $1 AND merchant_id = $2)`,
+		userID, merchantID,
+	).Scan(&granted)
+	if err != nil {
+		return fmt.Errorf("check consent: %w", err)
+	}
+	if !granted {
+		return ErrNoConsent
+	}
+	return nil
+}
+
+// RequireOwnedWithdrawal checks that the withdrawal paid for an order the
+// merchant registered or was made by redeeming one of its spend codes.
+func (s *MerchantService) RequireOwnedWithdrawal(ctx context.Context, merchantID, withdrawalID string) error {
+	var owned bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM withdrawals w
+			WHERE w.id = $1 AND (
+				EXISTS(SELECT 1 FROM spend_codes c WHERE c.withdrawal_id = w.id AND c.merchant_id = $2)
+				OR EXISTS(SELECT 1 FROM orders o WHERE o.number = w.order_number AND o.merchant_id::text = $2)
+			)
+		)
+	`, withdrawalID, merchantID).Scan(&owned)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrWithdrawalNotFound
+		}
+		return fmt.Errorf("check withdrawal merchant: %w", err)
+	}
+	if !owned {
+		return ErrNotMerchantOwned
+	}
+	return nil
+}
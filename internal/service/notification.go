@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gophermart/internal/model"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type NotificationService struct {
+	db *sql.DB
+}
+
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// notify stores a message for the user. Pass the surrounding transaction
+// so the notification is only kept if the event itself commits.
+func notify(ctx context.Context, ex execer, userID, kind, message string) error {
+	_, err := ex.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, kind, message) VALUES ($1, $2, $3)`,
+		userID, kind, message,
+	)
+	if err != nil {
+		return fmt.Errorf("insert notification: %w", err)
+	}
+	return nil
+}
+
+func (s *NotificationService) ListByUser(ctx context.Context, userID string, unreadOnly bool) ([]model.Notification, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, kind, message, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT 100
+	`, userID, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []model.Notification
+	for rows.Next() {
+		var n model.Notification
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Message, &n.CreatedAt, &readAt); err != nil {
+			return nil, fmt.Errorf("scan notification: %w", err)
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("mark notifications read: %w", err)
+	}
+	return nil
+}
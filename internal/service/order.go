@@ -16,15 +16,44 @@ var (
 	ErrOrderAlreadyExistsByOther = errors.New("order already uploaded by another user")
 )
 
+// AccrualHook runs inside the UpdateStatus transaction after an order's
+// accrual has been credited to its owner.
+type AccrualHook interface {
+	OnAccrual(ctx context.Context, tx *sql.Tx, ev AccrualEvent) error
+}
+
+type AccrualEvent struct {
+	UserID      string
+	OrderNumber string
+	Accrual     float64
+}
+
 type OrderService struct {
-	db *sql.DB
+	db    *sql.DB
+	hooks []AccrualHook
 }
 
 func NewOrderService(db *sql.DB) *OrderService {
 	return &OrderService{db: db}
 }
 
+// AddHook registers h to run on every credited accrual. Hooks run in the
+// order they were added.
+func (s *OrderService) AddHook(h AccrualHook) {
+	s.hooks = append(s.hooks, h)
+}
+
 func (s *OrderService) Create(ctx context.Context, userID, number string) error {
+	return s.create(ctx, userID, number, "")
+}
+
+// CreateForMerchant registers an order on the user's behalf and remembers
+// which merchant did it.
+func (s *OrderService) CreateForMerchant(ctx context.Context, merchantID, userID, number string) error {
+	return s.create(ctx, userID, number, merchantID)
+}
+
+func (s *OrderService) create(ctx context.Context, userID, number, merchantID string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
@@ -46,9 +75,14 @@ func (s *OrderService) Create(ctx context.Context, userID, number string) error
 		return fmt.Errorf("check order: %w", err)
 	}
 
+	var merchant any
+	if merchantID != "" {
+		merchant = merchantID
+	}
+
 	_, err = tx.ExecContext(ctx,
-		`INSERT INTO orders (user_id, number, status, uploaded_at) VALUES ($1, $2, $3, $4)`,
-		userID, number, "NEW", time.Now(),
+		`INSERT INTO orders (user_id, number, status, uploaded_at, merchant_id) VALUES ($1, $2, $3, $4, $5)`,
+		userID, number, "NEW", time.Now(), merchant,
 	)
 	if err != nil {
 		return fmt.Errorf("insert order: %w", err)
@@ -94,6 +128,42 @@ func (s *OrderService) ListByUser(ctx context.Context, userID string) ([]model.O
 	return orders, nil
 }
 
+func (s *OrderService) StreamByUser(ctx context.Context, userID string, period Period, fn func(model.Order) error) error {
+	from, to := period.args()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, number, status, accrual, uploaded_at
+		FROM orders
+		WHERE user_id = $1
+		  AND ($2::timestamptz IS NULL OR uploaded_at >= $2)
+		  AND ($3::timestamptz IS NULL OR uploaded_at < $3)
+		ORDER BY uploaded_at ASC
+	`, userID, from, to)
+	if err != nil {
+		return fmt.Errorf("query orders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o model.Order
+		var accrual sql.NullFloat64
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Number, &o.Status, &accrual, &o.UploadedAt); err != nil {
+			return fmt.Errorf("scan order: %w", err)
+		}
+		if accrual.Valid {
+			o.Accrual = accrual.Float64
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return nil
+}
+
 func (s *OrderService) UpdateStatus(ctx context.Context, number, status string, accrual *float64) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -101,17 +171,26 @@ func (s *OrderService) UpdateStatus(ctx context.Context, number, status string,
 	}
 	defer tx.Rollback()
 
+	// Final orders are left alone, so a status reported twice (by polling
+	// and by callback) is only credited once.
 	var query string
-	if accrual != nil {
-		query = `UPDATE orders SET status = $1, accrual = $2 WHERE number = $3`
-		_, err = tx.ExecContext(ctx, query, status, *accrual, number)
+	var res sql.Result
+	if status == "PROCESSED" && accrual != nil {
+		query = `UPDATE orders SET status = $1, accrual = $2, accrued_at = NOW() WHERE number = $3 AND status IN ('NEW', 'PROCESSING')`
+		res, err = tx.ExecContext(ctx, query, status, *accrual, number)
+	} else if accrual != nil {
+		query = `UPDATE orders SET status = $1, accrual = $2 WHERE number = $3 AND status IN ('NEW', 'PROCESSING')`
+		res, err = tx.ExecContext(ctx, query, status, *accrual, number)
 	} else {
-		query = `UPDATE orders SET status = $1 WHERE number = $2`
-		_, err = tx.ExecContext(ctx, query, status, number)
+		query = `UPDATE orders SET status = $1 WHERE number = $2 AND status IN ('NEW', 'PROCESSING')`
+		res, err = tx.ExecContext(ctx, query, status, number)
 	}
 	if err != nil {
 		return fmt.Errorf("update order: %w", err)
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
 
 	if status == "PROCESSED" && accrual != nil {
 		var userID string
@@ -120,9 +199,47 @@ func (s *OrderService) UpdateStatus(ctx context.Context, number, status string,
 			return fmt.Errorf("get user_id: %w", err)
 		}
 
-		_, err = tx.ExecContext(ctx, `UPDATE users SET current_balance = COALESCE(current_balance, 0) + $1 WHERE id = $2`, *accrual, userID)
+		// A pending referral rewards the referrer too, so the referrer's row
+		// is locked here together with the owner's, in id order like
+		// transfers, rather than later by the referral hook. User rows are
+		// locked before any wallet row, as in withdrawals.
+		locked := []string{userID}
+		var referrerID string
+		err = tx.QueryRowContext(ctx,
+			`SELECT referrer_id FROM referrals WHERE referee_id = $1 AND status = $2`,
+			userID, ReferralPending,
+		).Scan(&referrerID)
+		switch {
+		case err == nil:
+			locked = append(locked, referrerID)
+		case !errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("get referrer: %w", err)
+		}
+		if err = lockUsers(ctx, tx, locked...); err != nil {
+			return err
+		}
+
+		routed, err := routeAccrual(ctx, tx, userID, number, *accrual)
 		if err != nil {
-			return fmt.Errorf("update balance: %w", err)
+			return err
+		}
+
+		if !routed {
+			_, err = tx.ExecContext(ctx, `UPDATE users SET current_balance = COALESCE(current_balance, 0) + $1 WHERE id = $2`, *accrual, userID)
+			if err != nil {
+				return fmt.Errorf("update balance: %w", err)
+			}
+
+			if err = creditLot(ctx, tx, userID, "order:"+number, *accrual); err != nil {
+				return err
+			}
+		}
+
+		ev := AccrualEvent{UserID: userID, OrderNumber: number, Accrual: *accrual}
+		for _, h := range s.hooks {
+			if err = h.OnAccrual(ctx, tx, ev); err != nil {
+				return fmt.Errorf("accrual hook: %w", err)
+			}
 		}
 	}
 
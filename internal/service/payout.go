@@ -0,0 +1,396 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	PayoutNew        = "NEW"
+	PayoutSubmitting = "SUBMITTING"
+	PayoutPending    = "PENDING"
+	PayoutSucceeded  = "SUCCEEDED"
+	PayoutFailed     = "FAILED"
+
+	// payoutClaimTTL is how long a submitting payout stays claimed by the
+	// worker that picked it. A claim that outlives it, because the worker
+	// crashed mid-submit, is picked up and submitted again.
+	payoutClaimTTL = 5 * time.Minute
+)
+
+var ErrPayoutNotFound = errors.New("payout not found")
+
+type PayoutRequest struct {
+	ID          string  `json:"id"`
+	UserID      string  `json:"user_id"`
+	Destination string  `json:"destination"`
+	Sum         float64 `json:"sum"`
+}
+
+// PayoutProvider sends cash-out withdrawals to an external payment system.
+// Submit returns the provider's reference; Status reports PENDING,
+// SUCCEEDED or FAILED for it.
+//
+// Submit must be idempotent on req.ID, the withdrawal ID: a payout whose
+// submission was interrupted is submitted again, and the provider must then
+// return the reference of the payout it already has instead of paying twice.
+type PayoutProvider interface {
+	Submit(ctx context.Context, req PayoutRequest) (string, error)
+	Status(ctx context.Context, ref string) (string, error)
+}
+
+// FilePayoutProvider is a local stand-in that writes each payout to
+// <dir>/<ref>.json. Operators settle a payout by editing its status field.
+type FilePayoutProvider struct {
+	dir string
+}
+
+type filePayout struct {
+	PayoutRequest
+	Status string `json:"status"`
+}
+
+func NewFilePayoutProvider(dir string) (*FilePayoutProvider, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create payout dir: %w", err)
+	}
+	return &FilePayoutProvider{dir: dir}, nil
+}
+
+// Submit keys payouts by withdrawal ID, so submitting one again keeps the
+// existing file and its status.
+func (p *FilePayoutProvider) Submit(ctx context.Context, req PayoutRequest) (string, error) {
+	data, err := json.MarshalIndent(filePayout{PayoutRequest: req, Status: PayoutPending}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode payout: %w", err)
+	}
+	f, err := os.OpenFile(p.path(req.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return req.ID, nil
+		}
+		return "", fmt.Errorf("write payout: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", fmt.Errorf("write payout: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("write payout: %w", err)
+	}
+	return req.ID, nil
+}
+
+func (p *FilePayoutProvider) Status(ctx context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(p.path(ref))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrPayoutNotFound
+		}
+		return "", fmt.Errorf("read payout: %w", err)
+	}
+	var fp filePayout
+	if err := json.Unmarshal(data, &fp); err != nil {
+		return "", fmt.Errorf("decode payout: %w", err)
+	}
+	return fp.Status, nil
+}
+
+func (p *FilePayoutProvider) path(ref string) string {
+	return filepath.Join(p.dir, filepath.Base(ref)+".json")
+}
+
+// HTTPPayoutProvider talks to a payout service exposing
+// POST /payouts and GET /payouts/{ref}. Submissions carry the withdrawal ID
+// in the Idempotency-Key header.
+type HTTPPayoutProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+type httpPayoutResponse struct {
+	Ref    string `json:"ref"`
+	Status string `json:"status"`
+}
+
+func NewHTTPPayoutProvider(baseURL string) *HTTPPayoutProvider {
+	return &HTTPPayoutProvider{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *HTTPPayoutProvider) Submit(ctx context.Context, req PayoutRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("encode payout: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/payouts", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Idempotency-Key", req.ID)
+
+	res, err := p.do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	return res.Ref, nil
+}
+
+func (p *HTTPPayoutProvider) Status(ctx context.Context, ref string) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/payouts/"+ref, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	res, err := p.do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	return res.Status, nil
+}
+
+func (p *HTTPPayoutProvider) do(req *http.Request) (*httpPayoutResponse, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+		var res httpPayoutResponse
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		return &res, nil
+	case http.StatusNotFound:
+		return nil, ErrPayoutNotFound
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status: %d, body: %s", resp.StatusCode, string(body))
+	}
+}
+
+type PayoutService struct {
+	db            *sql.DB
+	provider      PayoutProvider
+	withdrawalSvc *WithdrawalService
+}
+
+func NewPayoutService(db *sql.DB, provider PayoutProvider, withdrawalSvc *WithdrawalService) *PayoutService {
+	return &PayoutService{db: db, provider: provider, withdrawalSvc: withdrawalSvc}
+}
+
+// Cashout debits points like any withdrawal. The payout itself is submitted
+// by the payout worker once the withdrawal is confirmed, so cash-outs above
+// the approval threshold wait for an admin first.
+func (s *PayoutService) Cashout(ctx context.Context, userID string, sum float64, destination string) (*model.Withdrawal, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate reference: %w", err)
+	}
+
+	return s.withdrawalSvc.create(ctx, model.Withdrawal{
+		UserID:       userID,
+		OrderNumber:  "cashout-" + token,
+		Sum:          sum,
+		Type:         WithdrawalTypeCashout,
+		Destination:  destination,
+		PayoutStatus: PayoutNew,
+	})
+}
+
+// SubmitNew hands confirmed cash-outs over to the provider. Payouts are
+// claimed as SUBMITTING before the provider is called, so two workers never
+// submit the same one; a claim left behind by a crash expires after
+// payoutClaimTTL and the payout is submitted again under the same
+// idempotency key. A payout that fails to submit is retried with backoff so
+// it doesn't hold up the rest of the queue; the errors of all failed
+// payouts are returned together.
+func (s *PayoutService) SubmitNew(ctx context.Context, limit int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE withdrawals
+		SET payout_status = $1, payout_retry_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM withdrawals
+			WHERE type = $3 AND status = $4 AND payout_status IN ($5, $1)
+			  AND (payout_retry_at IS NULL OR payout_retry_at <= NOW())
+			ORDER BY processed_at ASC
+			LIMIT $6
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, destination, sum
+	`, PayoutSubmitting, payoutClaimTTL.Seconds(), WithdrawalTypeCashout, WithdrawalConfirmed, PayoutNew, limit)
+	if err != nil {
+		return 0, fmt.Errorf("claim new payouts: %w", err)
+	}
+
+	var reqs []PayoutRequest
+	for rows.Next() {
+		var req PayoutRequest
+		if err := rows.Scan(&req.ID, &req.UserID, &req.Destination, &req.Sum); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan payout: %w", err)
+		}
+		reqs = append(reqs, req)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	submitted := 0
+	var errs []error
+	for _, req := range reqs {
+		ref, err := s.provider.Submit(ctx, req)
+		if err != nil {
+			errs = append(errs, s.deferPayout(ctx, req.ID, fmt.Errorf("submit payout %s: %w", req.ID, err)))
+			continue
+		}
+
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE withdrawals
+			SET payout_ref = $1, payout_status = $2, payout_attempts = 0, payout_retry_at = NULL, payout_error = ''
+			WHERE id = $3 AND payout_status = $4
+		`, ref, PayoutPending, req.ID, PayoutSubmitting)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("update payout %s: %w", req.ID, err))
+			continue
+		}
+		submitted++
+	}
+
+	return submitted, errors.Join(errs...)
+}
+
+// PollPending asks the provider about submitted payouts and applies final
+// statuses. Like SubmitNew it backs off payouts it can't settle and keeps
+// going with the others.
+func (s *PayoutService) PollPending(ctx context.Context, limit int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, payout_ref FROM withdrawals
+		WHERE type = $1 AND payout_status = $2
+		  AND (payout_retry_at IS NULL OR payout_retry_at <= NOW())
+		ORDER BY processed_at ASC
+		LIMIT $3
+	`, WithdrawalTypeCashout, PayoutPending, limit)
+	if err != nil {
+		return 0, fmt.Errorf("query pending payouts: %w", err)
+	}
+
+	type pending struct{ id, ref string }
+	var payouts []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.ref); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan payout ref: %w", err)
+		}
+		payouts = append(payouts, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	settled := 0
+	var errs []error
+	for _, p := range payouts {
+		status, err := s.provider.Status(ctx, p.ref)
+		if err != nil {
+			errs = append(errs, s.deferPayout(ctx, p.id, fmt.Errorf("payout %s status: %w", p.ref, err)))
+			continue
+		}
+		if status == PayoutPending {
+			continue
+		}
+		if err := s.Apply(ctx, p.ref, status); err != nil {
+			errs = append(errs, s.deferPayout(ctx, p.id, fmt.Errorf("apply payout %s: %w", p.ref, err)))
+			continue
+		}
+		settled++
+	}
+
+	return settled, errors.Join(errs...)
+}
+
+// deferPayout records cause on the payout and schedules the next attempt
+// with exponential backoff capped at an hour. It returns cause, joined with
+// the update error if the payout could not be deferred.
+func (s *PayoutService) deferPayout(ctx context.Context, id string, cause error) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE withdrawals
+		SET payout_attempts = payout_attempts + 1,
+		    payout_retry_at = NOW() + LEAST(INTERVAL '1 minute' * POWER(2, payout_attempts), INTERVAL '1 hour'),
+		    payout_error = $1
+		WHERE id = $2
+	`, cause.Error(), id)
+	if err != nil {
+		return errors.Join(cause, fmt.Errorf("defer payout %s: %w", id, err))
+	}
+	return cause
+}
+
+// Apply records a final payout status reported by polling or a provider
+// callback. A failed payout refunds the points. Repeated calls for an
+// already settled payout are ignored.
+func (s *PayoutService) Apply(ctx context.Context, ref, status string) error {
+	if status != PayoutSucceeded && status != PayoutFailed {
+		return fmt.Errorf("unknown payout status %q", status)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx,
+		`SELECT id FROM withdrawals WHERE type = $1 AND payout_ref = $2`,
+		WithdrawalTypeCashout, ref,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPayoutNotFound
+		}
+		return fmt.Errorf("get payout: %w", err)
+	}
+
+	wd, err := lockWithdrawal(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if wd.PayoutStatus != PayoutPending {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE withdrawals SET payout_status = $1 WHERE id = $2`, status, id)
+	if err != nil {
+		return fmt.Errorf("update payout: %w", err)
+	}
+
+	// a failed payout is the only way a cash-out gets refunded, see Reverse
+	if status == PayoutFailed && wd.Refunded < wd.Sum {
+		wd.PayoutStatus = PayoutFailed
+		if _, err := reverseLocked(ctx, tx, &wd, 0, "payout failed"); err != nil {
+			return fmt.Errorf("refund failed payout: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
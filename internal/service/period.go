@@ -0,0 +1,20 @@
+package service
+
+import "time"
+
+// Period bounds a history query. A zero From or To leaves that side open.
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+func (p Period) args() (any, any) {
+	var from, to any
+	if !p.From.IsZero() {
+		from = p.From
+	}
+	if !p.To.IsZero() {
+		to = p.To
+	}
+	return from, to
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes the services turn into their own errors.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgInvalidText         = "22P02"
+)
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// isUniqueViolation reports whether err was caused by the unique constraint
+// or index named constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == constraint
+}
+
+func isForeignKeyViolation(err error) bool {
+	return pgErrorCode(err) == pgForeignKeyViolation
+}
+
+// isInvalidText reports whether Postgres rejected a malformed value, such as
+// an ID that is not a UUID.
+func isInvalidText(err error) bool {
+	return pgErrorCode(err) == pgInvalidText
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+type ProfileService struct {
+	db      *sql.DB
+	tierSvc *TierService
+}
+
+func NewProfileService(db *sql.DB, tierSvc *TierService) *ProfileService {
+	return &ProfileService{db: db, tierSvc: tierSvc}
+}
+
+func (s *ProfileService) Get(ctx context.Context, userID string) (*model.Profile, error) {
+	var p model.Profile
+	var tierName string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT login, created_at, tier, referral_code FROM users WHERE id = $1`,
+		userID,
+	).Scan(&p.Login, &p.CreatedAt, &tierName, &p.ReferralCode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	tier := s.tierSvc.byName(tierName)
+	p.Tier = tier.Name
+	p.Multiplier = tier.Multiplier
+
+	p.TierScore, err = s.tierSvc.score(ctx, s.db, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if next := s.tierSvc.next(tier); next != nil {
+		p.NextTier = next.Name
+		p.NextTierThreshold = next.Threshold
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	ReferralPending  = "PENDING"
+	ReferralRewarded = "REWARDED"
+	ReferralRejected = "REJECTED"
+
+	BonusReferral = "REFERRAL_BONUS"
+)
+
+var ErrInvalidReferralCode = errors.New("invalid referral code")
+
+// ReferralPolicy configures the referral program. A zero Bonus disables
+// rewards; referrals are still recorded.
+type ReferralPolicy struct {
+	Bonus          float64
+	MaxPerReferrer int
+	MinAccrual     float64
+}
+
+// ReferralService rewards both sides of a referral once the referee's
+// first order is processed.
+type ReferralService struct {
+	db     *sql.DB
+	policy ReferralPolicy
+}
+
+func NewReferralService(db *sql.DB, policy ReferralPolicy) *ReferralService {
+	return &ReferralService{db: db, policy: policy}
+}
+
+// referralCodeAttempts bounds how often Register draws a new code after a
+// collision.
+const referralCodeAttempts = 5
+
+func newReferralCode() (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(token[:8]), nil
+}
+
+// attachReferral links a freshly registered user to the owner of code.
+func attachReferral(ctx context.Context, tx *sql.Tx, refereeID, code string) error {
+	var referrerID string
+	err := tx.QueryRowContext(ctx,
+		`SELECT id FROM users WHERE referral_code = $1 AND id <> $2`,
+		strings.ToUpper(strings.TrimSpace(code)), refereeID,
+	).Scan(&referrerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidReferralCode
+		}
+		return fmt.Errorf("get referrer: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO referrals (referrer_id, referee_id, status) VALUES ($1, $2, $3)`,
+		referrerID, refereeID, ReferralPending,
+	)
+	if err != nil {
+		return fmt.Errorf("insert referral: %w", err)
+	}
+	return nil
+}
+
+func (s *ReferralService) ListByReferrer(ctx context.Context, userID string) ([]model.Referral, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.login, r.status, r.reason, r.bonus, r.created_at, r.rewarded_at
+		FROM referrals r
+		JOIN users u ON u.id = r.referee_id
+		WHERE r.referrer_id = $1
+		ORDER BY r.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query referrals: %w", err)
+	}
+	defer rows.Close()
+
+	var referrals []model.Referral
+	for rows.Next() {
+		var ref model.Referral
+		var rewardedAt sql.NullTime
+		if err := rows.Scan(&ref.RefereeLogin, &ref.Status, &ref.Reason, &ref.Bonus, &ref.CreatedAt, &rewardedAt); err != nil {
+			return nil, fmt.Errorf("scan referral: %w", err)
+		}
+		if rewardedAt.Valid {
+			ref.RewardedAt = &rewardedAt.Time
+		}
+		referrals = append(referrals, ref)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return referrals, nil
+}
+
+func (s *ReferralService) OnAccrual(ctx context.Context, tx *sql.Tx, ev AccrualEvent) error {
+	if s.policy.Bonus <= 0 {
+		return nil
+	}
+
+	var id, referrerID string
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, referrer_id FROM referrals WHERE referee_id = $1 AND status = $2 FOR UPDATE`,
+		ev.UserID, ReferralPending,
+	).Scan(&id, &referrerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("get referral: %w", err)
+	}
+
+	// Serialises rewards per referrer so the cap below holds. UpdateStatus
+	// already holds this lock, taken in id order with the referee's.
+	_, err = tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, referrerID)
+	if err != nil {
+		return fmt.Errorf("lock referrer: %w", err)
+	}
+
+	reason, err := s.rejectReason(ctx, tx, referrerID, ev)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE referrals SET status = $2, reason = $3 WHERE id = $1`,
+			id, ReferralRejected, reason,
+		)
+		if err != nil {
+			return fmt.Errorf("reject referral: %w", err)
+		}
+		return nil
+	}
+
+	for _, userID := range []string{ev.UserID, referrerID} {
+		err := creditBonus(ctx, tx, bonus{
+			UserID:      userID,
+			Kind:        BonusReferral,
+			OrderNumber: ev.OrderNumber,
+			Amount:      s.policy.Bonus,
+		})
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("Referral bonus of %.2f points credited", roundPoints(s.policy.Bonus))
+		if err := notify(ctx, tx, userID, "REFERRAL_BONUS", message); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE referrals SET status = $2, bonus = $3, rewarded_at = $4 WHERE id = $1`,
+		id, ReferralRewarded, roundPoints(s.policy.Bonus), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("reward referral: %w", err)
+	}
+	return nil
+}
+
+// rejectReason applies the anti-abuse rules. Only the referee's first
+// processed order counts; a rejected referral is never reconsidered.
+func (s *ReferralService) rejectReason(ctx context.Context, tx *sql.Tx, referrerID string, ev AccrualEvent) (string, error) {
+	var processed, rewarded int
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM orders WHERE user_id = $1 AND status = 'PROCESSED'),
+			(SELECT COUNT(*) FROM referrals WHERE referrer_id = $2 AND status = $3)
+	`, ev.UserID, referrerID, ReferralRewarded).Scan(&processed, &rewarded)
+	if err != nil {
+		return "", fmt.Errorf("check referral: %w", err)
+	}
+
+	return s.policy.rejectReason(processed, rewarded, ev.Accrual), nil
+}
+
+// rejectReason decides on a referral given how many orders the referee has
+// processed, how many referrals the referrer already got rewarded for and
+// the accrual of the referee's order. It returns "" to reward it.
+func (p ReferralPolicy) rejectReason(processed, rewarded int, accrual float64) string {
+	switch {
+	case processed > 1:
+		return "not the first order"
+	case accrual < p.MinAccrual:
+		return "first order accrual below minimum"
+	case p.MaxPerReferrer > 0 && rewarded >= p.MaxPerReferrer:
+		return "referrer limit reached"
+	}
+	return ""
+}
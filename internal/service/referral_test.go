@@ -0,0 +1,31 @@
+package service
+
+import "testing"
+
+func TestReferralRejectReason(t *testing.T) {
+	policy := ReferralPolicy{Bonus: 50, MaxPerReferrer: 3, MinAccrual: 100}
+
+	tests := []struct {
+		name      string
+		policy    ReferralPolicy
+		processed int
+		rewarded  int
+		accrual   float64
+		want      string
+	}{
+		{name: "first order", policy: policy, processed: 1, accrual: 100},
+		{name: "later order", policy: policy, processed: 2, accrual: 500, want: "not the first order"},
+		{name: "small first order", policy: policy, processed: 1, accrual: 99.99, want: "first order accrual below minimum"},
+		{name: "referrer at the limit", policy: policy, processed: 1, rewarded: 3, accrual: 100, want: "referrer limit reached"},
+		{name: "referrer below the limit", policy: policy, processed: 1, rewarded: 2, accrual: 100},
+		{name: "no referrer limit", policy: ReferralPolicy{Bonus: 50}, processed: 1, rewarded: 1000, accrual: 1},
+		{name: "later order wins over other reasons", policy: policy, processed: 3, rewarded: 3, accrual: 1, want: "not the first order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.rejectReason(tt.processed, tt.rewarded, tt.accrual); got != tt.want {
+				t.Errorf("rejectReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
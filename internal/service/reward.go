@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+var (
+	ErrRewardNotFound    = errors.New("reward not found")
+	ErrRewardUnavailable = errors.New("reward is not available")
+	ErrInvalidReward     = errors.New("invalid reward")
+)
+
+const rewardColumns = `id, name, description, price, stock, starts_at, ends_at, active, created_at`
+
+// RewardService manages the rewards catalog. Redeeming a reward is a
+// withdrawal of type REWARD for the reward's price.
+type RewardService struct {
+	db            *sql.DB
+	withdrawalSvc *WithdrawalService
+}
+
+func NewRewardService(db *sql.DB, withdrawalSvc *WithdrawalService) *RewardService {
+	return &RewardService{db: db, withdrawalSvc: withdrawalSvc}
+}
+
+func scanReward(row interface{ Scan(...any) error }) (model.Reward, error) {
+	var r model.Reward
+	var stock sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	if err := row.Scan(&r.ID, &r.Name, &r.Description, &r.Price, &stock, &startsAt, &endsAt, &r.Active, &r.CreatedAt); err != nil {
+		return r, err
+	}
+	if stock.Valid {
+		n := int(stock.Int64)
+		r.Stock = &n
+	}
+	if startsAt.Valid {
+		r.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		r.EndsAt = &endsAt.Time
+	}
+	return r, nil
+}
+
+func validateReward(r model.Reward) error {
+	switch {
+	case r.Name == "":
+		return fmt.Errorf("%w: name required", ErrInvalidReward)
+	case r.Price <= 0:
+		return fmt.Errorf("%w: price must be positive", ErrInvalidReward)
+	case r.Stock != nil && *r.Stock < 0:
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidReward)
+	case r.StartsAt != nil && r.EndsAt != nil && !r.StartsAt.Before(*r.EndsAt):
+		return fmt.Errorf("%w: starts_at must be before ends_at", ErrInvalidReward)
+	}
+	return nil
+}
+
+func (s *RewardService) Create(ctx context.Context, r model.Reward) (*model.Reward, error) {
+	if err := validateReward(r); err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO rewards (name, description, price, stock, starts_at, ends_at, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+rewardColumns,
+		r.Name, r.Description, r.Price, r.Stock, r.StartsAt, r.EndsAt, r.Active,
+	)
+	created, err := scanReward(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert reward: %w", err)
+	}
+	return &created, nil
+}
+
+func (s *RewardService) Update(ctx context.Context, r model.Reward) (*model.Reward, error) {
+	if err := validateReward(r); err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE rewards
+		SET name = $2, description = $3, price = $4, stock = $5, starts_at = $6, ends_at = $7, active = $8
+		WHERE id = $1
+		RETURNING `+rewardColumns,
+		r.ID, r.Name, r.Description, r.Price, r.Stock, r.StartsAt, r.EndsAt, r.Active,
+	)
+	updated, err := scanReward(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil, ErrRewardNotFound
+		}
+		return nil, fmt.Errorf("update reward: %w", err)
+	}
+	return &updated, nil
+}
+
+func (s *RewardService) Get(ctx context.Context, id string) (*model.Reward, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+rewardColumns+` FROM rewards WHERE id = $1`, id)
+	r, err := scanReward(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil, ErrRewardNotFound
+		}
+		return nil, fmt.Errorf("get reward: %w", err)
+	}
+	return &r, nil
+}
+
+// List returns the whole catalog, or with availableOnly just what can be
+// redeemed right now.
+func (s *RewardService) List(ctx context.Context, availableOnly bool) ([]model.Reward, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+rewardColumns+` FROM rewards
+		WHERE NOT $1 OR (`+rewardAvailableSQL+`)
+		ORDER BY price ASC, name ASC
+	`, availableOnly, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("query rewards: %w", err)
+	}
+	defer rows.Close()
+
+	rewards := []model.Reward{}
+	for rows.Next() {
+		r, err := scanReward(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reward: %w", err)
+		}
+		rewards = append(rewards, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return rewards, nil
+}
+
+func (s *RewardService) Deactivate(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE rewards SET active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrRewardNotFound
+		}
+		return fmt.Errorf("deactivate reward: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRewardNotFound
+	}
+	return nil
+}
+
+// rewardAvailableSQL expects the current time as $2.
+const rewardAvailableSQL = `active
+	AND (stock IS NULL OR stock > 0)
+	AND (starts_at IS NULL OR starts_at <= $2)
+	AND (ends_at IS NULL OR ends_at > $2)`
+
+// Redeem takes one unit of stock and debits the reward's price in a single
+// transaction, so a failed debit leaves the stock untouched.
+func (s *RewardService) Redeem(ctx context.Context, userID, rewardID string) (*model.Redemption, error) {
+	code, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate redemption code: %w", err)
+	}
+	code = strings.ToUpper(code)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	red := model.Redemption{RewardID: rewardID, Code: code}
+	err = tx.QueryRowContext(ctx, `
+		UPDATE rewards SET stock = stock - 1
+		WHERE id = $1 AND (`+rewardAvailableSQL+`)
+		RETURNING name, price
+	`, rewardID, time.Now()).Scan(&red.RewardName, &red.Price)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			if _, getErr := s.Get(ctx, rewardID); errors.Is(getErr, ErrRewardNotFound) {
+				return nil, ErrRewardNotFound
+			}
+			return nil, ErrRewardUnavailable
+		}
+		return nil, fmt.Errorf("update reward stock: %w", err)
+	}
+
+	wd, err := s.withdrawalSvc.createTx(ctx, tx, model.Withdrawal{
+		UserID:      userID,
+		OrderNumber: "reward-" + code,
+		Sum:         red.Price,
+		Type:        WithdrawalTypeReward,
+	})
+	if err != nil {
+		return nil, err
+	}
+	red.WithdrawalID = wd.ID
+	red.Status = wd.Status
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO redemptions (user_id, reward_id, withdrawal_id, code, price)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, userID, rewardID, wd.ID, code, red.Price).Scan(&red.ID, &red.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert redemption: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &red, nil
+}
+
+func (s *RewardService) ListRedemptions(ctx context.Context, userID string) ([]model.Redemption, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.reward_id, r.name, d.withdrawal_id, d.code, d.price, w.status, d.created_at
+		FROM redemptions d
+		JOIN rewards r ON r.id = d.reward_id
+		JOIN withdrawals w ON w.id = d.withdrawal_id
+		WHERE d.user_id = $1
+		ORDER BY d.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	var redemptions []model.Redemption
+	for rows.Next() {
+		var d model.Redemption
+		if err := rows.Scan(&d.ID, &d.RewardID, &d.RewardName, &d.WithdrawalID, &d.Code, &d.Price, &d.Status, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan redemption: %w", err)
+		}
+		redemptions = append(redemptions, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return redemptions, nil
+}
+
+// restockReward puts back the unit taken by a redemption whose withdrawal
+// was rejected.
+func restockReward(ctx context.Context, tx *sql.Tx, withdrawalID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE rewards SET stock = stock + 1
+		WHERE stock IS NOT NULL AND id = (SELECT reward_id FROM redemptions WHERE withdrawal_id = $1)
+	`, withdrawalID)
+	if err != nil {
+		return fmt.Errorf("restock reward: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	ScheduleRunSucceeded       = "SUCCEEDED"
+	ScheduleRunFailed          = "FAILED"
+	ScheduleRunPendingApproval = "PENDING_APPROVAL"
+
+	// a schedule is paused after this many failed runs in a row
+	maxScheduleFailures = 3
+	minSchedulePeriod   = time.Hour
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidPeriod    = errors.New("period must be daily, weekly, monthly or a duration of at least 1h")
+)
+
+type ScheduleService struct {
+	db            *sql.DB
+	withdrawalSvc *WithdrawalService
+}
+
+func NewScheduleService(db *sql.DB, withdrawalSvc *WithdrawalService) *ScheduleService {
+	return &ScheduleService{db: db, withdrawalSvc: withdrawalSvc}
+}
+
+// nextRun returns the run one period after from. Monthly runs fall on
+// anchorDay, or on the last day of months that are shorter; a zero
+// anchorDay uses from's day.
+func nextRun(period string, from time.Time, anchorDay int) (time.Time, error) {
+	switch period {
+	case "daily":
+		return from.AddDate(0, 0, 1), nil
+	case "weekly":
+		return from.AddDate(0, 0, 7), nil
+	case "monthly":
+		if anchorDay == 0 {
+			anchorDay = from.Day()
+		}
+		return addMonth(from, anchorDay), nil
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d < minSchedulePeriod {
+		return time.Time{}, ErrInvalidPeriod
+	}
+	return from.Add(d), nil
+}
+
+// addMonth moves t to day anchorDay of the following month, clamped to that
+// month's last day, keeping the time of day. AddDate would normalise the
+// 31st into the next month and the schedule would drift from there.
+func addMonth(t time.Time, anchorDay int) time.Time {
+	first := time.Date(t.Year(), t.Month()+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(anchorDay, lastDay)-1)
+}
+
+const scheduleColumns = `id, user_id, reference, sum, period, next_run_at, active, failures, last_run_at, last_status, anchor_day, created_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (model.WithdrawalSchedule, error) {
+	var sc model.WithdrawalSchedule
+	var lastRunAt sql.NullTime
+	err := row.Scan(&sc.ID, &sc.UserID, &sc.Reference, &sc.Sum, &sc.Period, &sc.NextRunAt,
+		&sc.Active, &sc.Failures, &lastRunAt, &sc.LastStatus, &sc.AnchorDay, &sc.CreatedAt)
+	if lastRunAt.Valid {
+		sc.LastRunAt = &lastRunAt.Time
+	}
+	return sc, err
+}
+
+// Create stores a schedule whose first run happens at startAt, or one
+// period from now when startAt is zero.
+func (s *ScheduleService) Create(ctx context.Context, userID, reference string, sum float64, period string, startAt time.Time) (*model.WithdrawalSchedule, error) {
+	first, err := nextRun(period, time.Now(), 0)
+	if err != nil {
+		return nil, err
+	}
+	if !startAt.IsZero() {
+		first = startAt
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO withdrawal_schedules (user_id, reference, sum, period, next_run_at, anchor_day)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+scheduleColumns,
+		userID, reference, sum, period, first, first.Day(),
+	)
+	sc, err := scanSchedule(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert schedule: %w", err)
+	}
+	return &sc, nil
+}
+
+func (s *ScheduleService) ListByUser(ctx context.Context, userID string) ([]model.WithdrawalSchedule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+scheduleColumns+` FROM withdrawal_schedules WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []model.WithdrawalSchedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan schedule: %w", err)
+		}
+		schedules = append(schedules, sc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (s *ScheduleService) Deactivate(ctx context.Context, userID, scheduleID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE withdrawal_schedules SET active = FALSE WHERE id = $1 AND user_id = $2`,
+		scheduleID, userID,
+	)
+	if err != nil {
+		if isInvalidText(err) {
+			return ErrScheduleNotFound
+		}
+		return fmt.Errorf("deactivate schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (s *ScheduleService) ListRuns(ctx context.Context, userID, scheduleID string) ([]model.ScheduleRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.schedule_id, r.status, COALESCE(r.withdrawal_id::text, ''), r.error, r.ran_at
+		FROM withdrawal_schedule_runs r
+		JOIN withdrawal_schedules sc ON sc.id = r.schedule_id
+		WHERE r.schedule_id = $1 AND sc.user_id = $2
+		ORDER BY r.ran_at DESC
+		LIMIT 100
+	`, scheduleID, userID)
+	if err != nil {
+		if isInvalidText(err) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, fmt.Errorf("query schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []model.ScheduleRun
+	for rows.Next() {
+		var run model.ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.Status, &run.WithdrawalID, &run.Error, &run.RanAt); err != nil {
+			return nil, fmt.Errorf("scan schedule run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return runs, nil
+}
+
+// RunDue executes every active schedule whose next run is not after now.
+// Schedules backing off after a system error wait until their retry time.
+func (s *ScheduleService) RunDue(ctx context.Context, now time.Time, limit int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+scheduleColumns+` FROM withdrawal_schedules
+		WHERE active AND next_run_at <= $1 AND (retry_at IS NULL OR retry_at <= $1)
+		ORDER BY next_run_at ASC
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("query due schedules: %w", err)
+	}
+
+	var due []model.WithdrawalSchedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan schedule: %w", err)
+		}
+		due = append(due, sc)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	// one broken schedule must not hold up the others
+	ran := 0
+	var errs []error
+	for _, sc := range due {
+		ok, err := s.run(ctx, sc, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("run schedule %s: %w", sc.ID, err))
+			continue
+		}
+		if ok {
+			ran++
+		}
+	}
+
+	return ran, errors.Join(errs...)
+}
+
+func (s *ScheduleService) run(ctx context.Context, sc model.WithdrawalSchedule, now time.Time) (bool, error) {
+	next, err := nextRun(sc.Period, sc.NextRunAt, sc.AnchorDay)
+	if err != nil {
+		return false, err
+	}
+	// skip periods missed while the service was down
+	for !next.After(now) {
+		if next, err = nextRun(sc.Period, next, sc.AnchorDay); err != nil {
+			return false, err
+		}
+	}
+
+	// claim the run so a concurrent instance does not execute it twice
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE withdrawal_schedules SET next_run_at = $1 WHERE id = $2 AND next_run_at = $3 AND active`,
+		next, sc.ID, sc.NextRunAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("claim schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	orderNumber := fmt.Sprintf("%s-%s", sc.Reference, sc.NextRunAt.UTC().Format("20060102150405"))
+	wd, runErr := s.withdrawalSvc.Create(ctx, sc.UserID, orderNumber, sc.Sum)
+
+	status, withdrawalID, errText := ScheduleRunSucceeded, any(nil), ""
+	failures := 0
+	message := fmt.Sprintf("Scheduled withdrawal of %.2f for %s completed", sc.Sum, sc.Reference)
+	if runErr != nil {
+		if !isScheduleRunFailure(runErr) {
+			// give the period back and retry it with exponential backoff
+			// capped at an hour, so a schedule that keeps hitting the same
+			// error doesn't take a place in every batch
+			_, err := s.db.ExecContext(ctx, `
+				UPDATE withdrawal_schedules
+				SET next_run_at = $1,
+				    retry_attempts = retry_attempts + 1,
+				    retry_at = $2 + LEAST(INTERVAL '1 minute' * POWER(2, retry_attempts), INTERVAL '1 hour')
+				WHERE id = $3
+			`, sc.NextRunAt, now, sc.ID)
+			if err != nil {
+				return false, errors.Join(runErr, fmt.Errorf("defer schedule: %w", err))
+			}
+			return false, runErr
+		}
+		status, errText = ScheduleRunFailed, runErr.Error()
+		failures = sc.Failures + 1
+		message = fmt.Sprintf("Scheduled withdrawal of %.2f for %s failed: %s", sc.Sum, sc.Reference, errText)
+		if failures >= maxScheduleFailures {
+			message += fmt.Sprintf("; schedule paused after %d failures", failures)
+		}
+	} else {
+		withdrawalID = wd.ID
+		if wd.Status == WithdrawalPendingApproval {
+			status = ScheduleRunPendingApproval
+			message = fmt.Sprintf("Scheduled withdrawal of %.2f for %s is waiting for approval", sc.Sum, sc.Reference)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO withdrawal_schedule_runs (schedule_id, status, withdrawal_id, error, ran_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, sc.ID, status, withdrawalID, errText, now)
+	if err != nil {
+		return false, fmt.Errorf("insert schedule run: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE withdrawal_schedules
+		SET last_run_at = $1, last_status = $2, failures = $3, active = active AND $3 < $4,
+		    retry_attempts = 0, retry_at = NULL
+		WHERE id = $5
+	`, now, status, failures, maxScheduleFailures, sc.ID)
+	if err != nil {
+		return false, fmt.Errorf("update schedule: %w", err)
+	}
+
+	if err := notify(ctx, tx, sc.UserID, "SCHEDULED_WITHDRAWAL", message); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// isScheduleRunFailure reports errors caused by the user's state rather
+// than by the system; those are recorded as failed runs.
+func isScheduleRunFailure(err error) bool {
+	return errors.Is(err, ErrInsufficientFunds) ||
+		errors.Is(err, ErrWithdrawalLimit) ||
+		errors.Is(err, ErrWithdrawalOrderUsed) ||
+		errors.Is(err, ErrWithdrawalOrderForeign)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 9, 30, 0, 0, time.UTC)
+}
+
+func TestNextRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		period string
+		from   time.Time
+		anchor int
+		want   time.Time
+	}{
+		{name: "daily", period: "daily", from: date(2025, 3, 31), want: date(2025, 4, 1)},
+		{name: "weekly", period: "weekly", from: date(2025, 12, 29), want: date(2026, 1, 5)},
+		{name: "duration", period: "72h", from: date(2025, 2, 27), want: date(2025, 3, 2)},
+		{name: "monthly", period: "monthly", from: date(2025, 1, 15), want: date(2025, 2, 15)},
+		{name: "monthly into december", period: "monthly", from: date(2025, 11, 15), want: date(2025, 12, 15)},
+		{name: "monthly over new year", period: "monthly", from: date(2025, 12, 15), want: date(2026, 1, 15)},
+		{name: "monthly clamped to february", period: "monthly", from: date(2025, 1, 31), anchor: 31, want: date(2025, 2, 28)},
+		{name: "monthly clamped to leap february", period: "monthly", from: date(2024, 1, 30), anchor: 30, want: date(2024, 2, 29)},
+		{name: "monthly back to anchor", period: "monthly", from: date(2025, 2, 28), anchor: 31, want: date(2025, 3, 31)},
+		{name: "monthly clamped to april", period: "monthly", from: date(2025, 3, 31), anchor: 31, want: date(2025, 4, 30)},
+		{name: "monthly without anchor", period: "monthly", from: date(2025, 2, 28), want: date(2025, 3, 28)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextRun(tt.period, tt.from, tt.anchor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextRun(%q, %v, %d) = %v, want %v", tt.period, tt.from, tt.anchor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRunInvalidPeriod(t *testing.T) {
+	for _, period := range []string{"", "yearly", "30m", "-24h", "1d"} {
+		if _, err := nextRun(period, date(2025, 1, 1), 0); !errors.Is(err, ErrInvalidPeriod) {
+			t.Errorf("nextRun(%q) = %v, want ErrInvalidPeriod", period, err)
+		}
+	}
+}
+
+// A year of monthly runs anchored on the 31st must not drift.
+func TestNextRunMonthlyKeepsAnchor(t *testing.T) {
+	run := date(2025, 1, 31)
+	for range 12 {
+		next, err := nextRun("monthly", run, 31)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastDay := time.Date(next.Year(), next.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day(); next.Day() != lastDay {
+			t.Fatalf("run after %v = %v, want the month's last day", run, next)
+		}
+		run = next
+	}
+	if want := date(2026, 1, 31); !run.Equal(want) {
+		t.Errorf("twelfth run = %v, want %v", run, want)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gophermart/internal/model"
+)
+
+// ListSessions returns the user's active sessions, those that still hold an
+// unused, unexpired refresh token. The session currentJTI belongs to is
+// flagged as current.
+func (s *TokenService) ListSessions(ctx context.Context, userID, currentJTI string) ([]model.Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id, s.user_agent, s.ip, s.created_at, s.last_seen_at,
+		       EXISTS(SELECT 1 FROM access_tokens a WHERE a.family_id = s.id AND a.jti::text = $2)
+		FROM sessions s
+		WHERE s.user_id = $1 AND s.terminated_at IS NULL
+		  AND EXISTS(
+		      SELECT 1 FROM refresh_tokens r
+		      WHERE r.family_id = s.id AND r.revoked_at IS NULL AND r.used_at IS NULL AND r.expires_at > NOW()
+		  )
+		ORDER BY s.last_seen_at DESC
+	`, userID, currentJTI)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var sess model.Session
+		if err := rows.Scan(&sess.ID, &sess.UserAgent, &sess.IP, &sess.CreatedAt, &sess.LastSeenAt, &sess.Current); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		sessions = append(sessions, sess)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// TerminateSession ends one of the user's sessions and revokes its tokens.
+func (s *TokenService) TerminateSession(ctx context.Context, userID, sessionID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var active bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT terminated_at IS NULL FROM sessions WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		sessionID, userID,
+	).Scan(&active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("get session: %w", err)
+	}
+	if !active {
+		return ErrSessionNotFound
+	}
+
+	if err := terminateSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	spendCodeQRPrefix = "gophermart:spend:"
+	// spendCodeAttempts bounds how often Issue draws a new code after a
+	// collision.
+	spendCodeAttempts = 5
+)
+
+var (
+	ErrSpendCodeNotFound = errors.New("spend code not found")
+	ErrSpendCodeUsed     = errors.New("spend code already redeemed")
+	ErrSpendCodeExpired  = errors.New("spend code expired or cancelled")
+
+	errSpendCodeTaken = errors.New("spend code taken")
+)
+
+// SpendCodeService issues one-time codes for in-store spending. A code is
+// backed by an IN_STORE reservation, so an unredeemed code is released by
+// the hold sweeper and the user can revoke it by cancelling the withdrawal.
+type SpendCodeService struct {
+	db            *sql.DB
+	withdrawalSvc *WithdrawalService
+	ttl           time.Duration
+}
+
+func NewSpendCodeService(db *sql.DB, withdrawalSvc *WithdrawalService, ttl time.Duration) *SpendCodeService {
+	return &SpendCodeService{db: db, withdrawalSvc: withdrawalSvc, ttl: ttl}
+}
+
+// Issue reserves sum and returns a fresh code for it. Codes are short, so
+// one that is already taken, as a code or as the order number it reserves
+// under, is drawn again.
+func (s *SpendCodeService) Issue(ctx context.Context, userID string, sum float64) (*model.SpendCode, error) {
+	for range spendCodeAttempts {
+		token, err := GenerateToken()
+		if err != nil {
+			return nil, fmt.Errorf("generate spend code: %w", err)
+		}
+
+		sc, err := s.issue(ctx, userID, sum, strings.ToUpper(token[:10]))
+		if errors.Is(err, errSpendCodeTaken) {
+			continue
+		}
+		return sc, err
+	}
+	return nil, errors.New("generate spend code: no free code found")
+}
+
+func (s *SpendCodeService) issue(ctx context.Context, userID string, sum float64, code string) (*model.SpendCode, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := s.withdrawalSvc.reserveTx(ctx, tx, model.Withdrawal{
+		UserID:      userID,
+		OrderNumber: "code-" + code,
+		Sum:         sum,
+		Type:        WithdrawalTypeInStore,
+	}, s.ttl)
+	if err != nil {
+		if errors.Is(err, ErrWithdrawalOrderUsed) {
+			return nil, errSpendCodeTaken
+		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO spend_codes (code, user_id, withdrawal_id) VALUES ($1, $2, $3)`,
+		code, userID, wd.ID,
+	)
+	if err != nil {
+		if isUniqueViolation(err, "spend_codes_pkey") {
+			return nil, errSpendCodeTaken
+		}
+		return nil, fmt.Errorf("insert spend code: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &model.SpendCode{
+		Code:         code,
+		QRPayload:    spendCodeQRPrefix + code,
+		WithdrawalID: wd.ID,
+		Sum:          wd.Sum,
+		ExpiresAt:    *wd.ExpiresAt,
+	}, nil
+}
+
+// Redeem turns the code's reservation into a confirmed withdrawal for
+// orderNumber. The code may be given as printed or as its QR payload.
+func (s *SpendCodeService) Redeem(ctx context.Context, merchantID, code, orderNumber string) (*model.Withdrawal, error) {
+	code = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(code), spendCodeQRPrefix))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var withdrawalID string
+	var redeemedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`SELECT withdrawal_id, redeemed_at FROM spend_codes WHERE code = $1 FOR UPDATE`,
+		code,
+	).Scan(&withdrawalID, &redeemedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSpendCodeNotFound
+		}
+		return nil, fmt.Errorf("get spend code: %w", err)
+	}
+	if redeemedAt.Valid {
+		return nil, ErrSpendCodeUsed
+	}
+
+	wd, err := lockWithdrawal(ctx, tx, withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	if wd.Status != WithdrawalReserved || wd.ExpiresAt == nil || !wd.ExpiresAt.After(time.Now()) {
+		return nil, ErrSpendCodeExpired
+	}
+
+	if err := s.withdrawalSvc.checkOrder(ctx, tx, wd.UserID, orderNumber); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE withdrawals SET order_number = $1 WHERE id = $2`, orderNumber, wd.ID)
+	if err != nil {
+		if isUniqueViolation(err, "uq_withdrawals_active_order") {
+			return nil, ErrWithdrawalOrderUsed
+		}
+		return nil, fmt.Errorf("update withdrawal: %w", err)
+	}
+	wd.OrderNumber = orderNumber
+
+	if err := settleHold(ctx, tx, &wd); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE spend_codes SET redeemed_at = $1, merchant_id = $2 WHERE code = $3`,
+		wd.ProcessedAt, merchantID, code,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update spend code: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &wd, nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TierBasisLifetime = "lifetime"
+	TierBasisRolling  = "rolling"
+)
+
+type Tier struct {
+	Name       string  `json:"name"`
+	Threshold  float64 `json:"threshold"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// ParseTiers reads tiers in the NAME:THRESHOLD:MULTIPLIER[,...] format,
+// e.g. "BRONZE:0:1,SILVER:1000:1.1,GOLD:5000:1.25". The lowest threshold
+// must be zero so every user has a tier.
+func ParseTiers(spec string) ([]Tier, error) {
+	var tiers []Tier
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid tier %q", part)
+		}
+		threshold, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier threshold %q: %w", part, err)
+		}
+		multiplier, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || multiplier < 1 {
+			return nil, fmt.Errorf("invalid tier multiplier %q", part)
+		}
+		tiers = append(tiers, Tier{Name: fields[0], Threshold: threshold, Multiplier: multiplier})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Threshold < tiers[j].Threshold })
+	if len(tiers) == 0 || tiers[0].Threshold != 0 {
+		return nil, errors.New("the lowest tier must have threshold 0")
+	}
+	return tiers, nil
+}
+
+// TierService keeps users' loyalty tiers up to date. The tier score is the
+// user's lifetime accrual or, with the rolling basis, the accrual of the
+// last 12 months. With multipliers enabled a tier above 1x adds a
+// TIER_BONUS on top of each accrual.
+type TierService struct {
+	tiers           []Tier
+	basis           string
+	applyMultiplier bool
+}
+
+func NewTierService(tiers []Tier, basis string, applyMultiplier bool) *TierService {
+	return &TierService{tiers: tiers, basis: basis, applyMultiplier: applyMultiplier}
+}
+
+func (s *TierService) OnAccrual(ctx context.Context, tx *sql.Tx, ev AccrualEvent) error {
+	var current string
+	if err := tx.QueryRowContext(ctx, `SELECT tier FROM users WHERE id = $1`, ev.UserID).Scan(&current); err != nil {
+		return fmt.Errorf("get tier: %w", err)
+	}
+
+	// the bonus uses the tier the user had before this accrual
+	if s.applyMultiplier {
+		if m := s.byName(current).Multiplier; m > 1 {
+			err := creditBonus(ctx, tx, bonus{
+				UserID:      ev.UserID,
+				Kind:        BonusTier,
+				OrderNumber: ev.OrderNumber,
+				Amount:      ev.Accrual * (m - 1),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	score, err := s.score(ctx, tx, ev.UserID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	next := s.forScore(score)
+	if next.Name == s.byName(current).Name {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET tier = $1, tier_updated_at = NOW() WHERE id = $2`, next.Name, ev.UserID)
+	if err != nil {
+		return fmt.Errorf("update tier: %w", err)
+	}
+
+	return notify(ctx, tx, ev.UserID, "TIER_CHANGED", fmt.Sprintf("Your loyalty tier is now %s", next.Name))
+}
+
+func (s *TierService) score(ctx context.Context, q queryer, userID string, now time.Time) (float64, error) {
+	var since any
+	if s.basis == TierBasisRolling {
+		since = now.AddDate(-1, 0, 0)
+	}
+
+	var score float64
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(accrual), 0) FROM orders
+		WHERE user_id = $1 AND status = 'PROCESSED'
+		  AND ($2::timestamptz IS NULL OR accrued_at >= $2)
+	`, userID, since).Scan(&score)
+	if err != nil {
+		return 0, fmt.Errorf("get tier score: %w", err)
+	}
+	return score, nil
+}
+
+func (s *TierService) forScore(score float64) Tier {
+	t := s.tiers[0]
+	for _, candidate := range s.tiers {
+		if score >= candidate.Threshold {
+			t = candidate
+		}
+	}
+	return t
+}
+
+// byName falls back to the lowest tier for users who were never evaluated.
+func (s *TierService) byName(name string) Tier {
+	for _, t := range s.tiers {
+		if t.Name == name {
+			return t
+		}
+	}
+	return s.tiers[0]
+}
+
+// next returns the tier above t, or nil at the top.
+func (s *TierService) next(t Tier) *Tier {
+	for i := range s.tiers {
+		if s.tiers[i].Threshold > t.Threshold {
+			return &s.tiers[i]
+		}
+	}
+	return nil
+}
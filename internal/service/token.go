@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"gophermart/internal/model"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// ClientInfo describes the device a session was started from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// TokenService issues short-lived access tokens and rotating refresh
+// tokens. Every login starts a session whose id is the token family; each
+// refresh consumes the presented refresh token and issues the next one in
+// the same family. Presenting a consumed refresh token again means it
+// leaked, so the whole family is revoked. Access token IDs (jti) are stored
+// so that logout can revoke them before they expire.
+type TokenService struct {
+	db         *sql.DB
+	keys       *Keyset
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenService(db *sql.DB, keys *Keyset, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{db: db, keys: keys, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+// Issue starts a new session for userID.
+func (s *TokenService) Issue(ctx context.Context, userID string, client ClientInfo) (*model.TokenPair, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var familyID string
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO sessions (user_id, user_agent, ip) VALUES ($1, $2, $3) RETURNING id`,
+		userID, client.UserAgent, client.IP,
+	).Scan(&familyID)
+	if err != nil {
+		return nil, fmt.Errorf("insert session: %w", err)
+	}
+
+	pair, err := s.issueTx(ctx, tx, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return pair, nil
+}
+
+func (s *TokenService) issueTx(ctx context.Context, tx *sql.Tx, userID, familyID string) (*model.TokenPair, error) {
+	now := time.Now()
+	pair := model.TokenPair{
+		TokenType:        "Bearer",
+		ExpiresAt:        now.Add(s.accessTTL),
+		RefreshExpiresAt: now.Add(s.refreshTTL),
+	}
+
+	var jti string
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO access_tokens (user_id, family_id, expires_at) VALUES ($1, $2, $3)
+		RETURNING jti
+	`, userID, familyID, pair.ExpiresAt).Scan(&jti)
+	if err != nil {
+		return nil, fmt.Errorf("insert access token: %w", err)
+	}
+
+	pair.AccessToken, err = s.keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"jti":     jti,
+		"iat":     jwt.NewNumericDate(now),
+		"exp":     jwt.NewNumericDate(pair.ExpiresAt),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign access token: %w", err)
+	}
+
+	a, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+	b, err := GenerateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+	pair.RefreshToken = a + b
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+	`, userID, familyID, hashRefreshToken(pair.RefreshToken), pair.RefreshExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert refresh token: %w", err)
+	}
+
+	return &pair, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*model.TokenPair, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id, userID, familyID string
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashRefreshToken(refreshToken)).Scan(&id, &userID, &familyID, &expiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+
+	if revokedAt.Valid {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if usedAt.Valid {
+		if err := terminateSession(ctx, tx, familyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit tx: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("consume refresh token: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE sessions SET last_seen_at = NOW(), user_agent = $1, ip = $2 WHERE id = $3`,
+		client.UserAgent, client.IP, familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update session: %w", err)
+	}
+
+	pair, err := s.issueTx(ctx, tx, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return pair, nil
+}
+
+// Logout terminates the session the access token jti belongs to.
+func (s *TokenService) Logout(ctx context.Context, userID, jti string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var familyID string
+	err = tx.QueryRowContext(ctx,
+		`SELECT family_id FROM access_tokens WHERE jti = $1 AND user_id = $2`,
+		jti, userID,
+	).Scan(&familyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil
+		}
+		return fmt.Errorf("get access token: %w", err)
+	}
+
+	if err := terminateSession(ctx, tx, familyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LogoutAll revokes every token of userID.
+func (s *TokenService) LogoutAll(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE sessions SET terminated_at = NOW() WHERE user_id = $1 AND terminated_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("terminate sessions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke access tokens: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke refresh tokens: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// IsRevoked implements mw.TokenRevocationChecker. Tokens of terminated
+// sessions and unknown token IDs count as revoked. The session's
+// last_seen_at is written at most once a minute.
+func (s *TokenService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	var sessionID string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT a.revoked_at IS NOT NULL OR s.terminated_at IS NOT NULL, a.family_id
+		FROM access_tokens a
+		LEFT JOIN sessions s ON s.id = a.family_id
+		WHERE a.jti = $1
+	`, jti).Scan(&revoked, &sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("get access token: %w", err)
+	}
+	if revoked {
+		return true, nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE sessions SET last_seen_at = NOW()
+		WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("update session: %w", err)
+	}
+	return false, nil
+}
+
+func terminateSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET terminated_at = NOW() WHERE id = $1 AND terminated_at IS NULL`,
+		sessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("terminate session: %w", err)
+	}
+	return revokeFamily(ctx, tx, sessionID)
+}
+
+func revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE access_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`,
+		familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke access tokens: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`,
+		familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
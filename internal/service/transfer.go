@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gophermart/internal/model"
+)
+
+const (
+	TransferIn  = "IN"
+	TransferOut = "OUT"
+)
+
+var (
+	ErrTransferRecipient = errors.New("transfer recipient not found")
+	ErrTransferSelf      = errors.New("cannot transfer to yourself")
+	ErrTransferLimit     = errors.New("transfer limit exceeded")
+	ErrTransferAmount    = errors.New("transfer amount must be at least 0.01")
+)
+
+// TransferLimits apply to the sender. Zero values disable the rule; the
+// daily cap follows UTC calendar days.
+type TransferLimits struct {
+	MinAmount float64
+	MaxSingle float64
+	DailyCap  float64
+}
+
+type TransferService struct {
+	db     *sql.DB
+	limits TransferLimits
+}
+
+func NewTransferService(db *sql.DB, limits TransferLimits) *TransferService {
+	return &TransferService{db: db, limits: limits}
+}
+
+// Transfer moves amount from the sender to the user with login toLogin.
+// Both user rows are locked in id order so concurrent transfers in opposite
+// directions cannot deadlock. The sender's points leave their lots oldest
+// first and reach the recipient as lots of the same age, so a transfer
+// doesn't reset their expiry.
+func (s *TransferService) Transfer(ctx context.Context, fromUserID, toLogin string, amount float64, comment string) (*model.Transfer, error) {
+	amount = roundPoints(amount)
+	if amount <= 0 {
+		return nil, ErrTransferAmount
+	}
+
+	if s.limits.MinAmount > 0 && amount < s.limits.MinAmount {
+		return nil, fmt.Errorf("%w: minimum transfer is %.2f", ErrTransferLimit, s.limits.MinAmount)
+	}
+	if s.limits.MaxSingle > 0 && amount > s.limits.MaxSingle {
+		return nil, fmt.Errorf("%w: maximum single transfer is %.2f", ErrTransferLimit, s.limits.MaxSingle)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var toUserID string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE login = $1`, toLogin).Scan(&toUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferRecipient
+		}
+		return nil, fmt.Errorf("get recipient: %w", err)
+	}
+	if toUserID == fromUserID {
+		return nil, ErrTransferSelf
+	}
+
+	if err := lockUsers(ctx, tx, fromUserID, toUserID); err != nil {
+		return nil, err
+	}
+
+	if s.limits.DailyCap > 0 {
+		now := time.Now().UTC()
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+		var sent float64
+		err := tx.QueryRowContext(ctx,
+			`SELECT COALESCE(SUM(amount), 0) FROM transfers WHERE from_user_id = $1 AND created_at >= $2`,
+			fromUserID, dayStart,
+		).Scan(&sent)
+		if err != nil {
+			return nil, fmt.Errorf("get transfer totals: %w", err)
+		}
+		if left := *remaining(s.limits.DailyCap, sent); amount > left {
+			return nil, fmt.Errorf("%w: daily remaining is %.2f", ErrTransferLimit, left)
+		}
+	}
+
+	if err := debitBalance(ctx, tx, fromUserID, amount); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET current_balance = COALESCE(current_balance, 0) + $1 WHERE id = $2`, amount, toUserID)
+	if err != nil {
+		return nil, fmt.Errorf("update balance: %w", err)
+	}
+
+	t := model.Transfer{Direction: TransferOut, Counterparty: toLogin, Amount: amount, Comment: comment}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO transfers (from_user_id, to_user_id, amount, comment)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, fromUserID, toUserID, amount, comment).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert transfer: %w", err)
+	}
+
+	if err := moveLots(ctx, tx, fromUserID, toUserID, "transfer:"+t.ID, amount); err != nil {
+		return nil, err
+	}
+
+	var fromLogin string
+	if err := tx.QueryRowContext(ctx, `SELECT login FROM users WHERE id = $1`, fromUserID).Scan(&fromLogin); err != nil {
+		return nil, fmt.Errorf("get sender: %w", err)
+	}
+	message := fmt.Sprintf("%s sent you %.2f points", fromLogin, amount)
+	if err := notify(ctx, tx, toUserID, "TRANSFER_RECEIVED", message); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &t, nil
+}
+
+func (s *TransferService) ListByUser(ctx context.Context, userID string) ([]model.Transfer, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id,
+		       CASE WHEN t.from_user_id = $1 THEN 'OUT' ELSE 'IN' END,
+		       u.login, t.amount, t.comment, t.created_at
+		FROM transfers t
+		JOIN users u ON u.id = CASE WHEN t.from_user_id = $1 THEN t.to_user_id ELSE t.from_user_id END
+		WHERE t.from_user_id = $1 OR t.to_user_id = $1
+		ORDER BY t.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query transfers: %w", err)
+	}
+	defer rows.Close()
+
+	var transfers []model.Transfer
+	for rows.Next() {
+		var t model.Transfer
+		if err := rows.Scan(&t.ID, &t.Direction, &t.Counterparty, &t.Amount, &t.Comment, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan transfer: %w", err)
+		}
+		transfers = append(transfers, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// lockUsers locks the rows of several users in id order. Every transaction
+// that holds more than one user row takes them this way so none of them can
+// deadlock against another.
+func lockUsers(ctx context.Context, tx *sql.Tx, userIDs ...string) error {
+	ids := slices.Clone(userIDs)
+	slices.Sort(ids)
+	for _, id := range slices.Compact(ids) {
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, id); err != nil {
+			return fmt.Errorf("lock user: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gophermart/internal/model"
+)
+
+const (
+	WalletOwner  = "OWNER"
+	WalletMember = "MEMBER"
+
+	InvitePending  = "PENDING"
+	InviteAccepted = "ACCEPTED"
+	InviteDeclined = "DECLINED"
+)
+
+var (
+	ErrWalletNotFound       = errors.New("wallet not found")
+	ErrWalletForbidden      = errors.New("not allowed for this wallet member")
+	ErrWalletMemberExists   = errors.New("user is already a wallet member")
+	ErrWalletMemberNotFound = errors.New("wallet member not found")
+	ErrWalletOwner          = errors.New("wallet owner cannot be changed or removed")
+	ErrWalletInviteNotFound = errors.New("wallet invite not found")
+)
+
+// WalletPermissions are what the owner grants a member. A zero
+// MaxWithdrawal means no per-withdrawal cap.
+type WalletPermissions struct {
+	CanWithdraw   bool
+	MaxWithdrawal float64
+}
+
+// WalletService manages shared wallets. A member may route their accruals
+// to at most one wallet; withdrawals from a wallet go through
+// WithdrawalService.CreateFromWallet.
+type WalletService struct {
+	db *sql.DB
+}
+
+func NewWalletService(db *sql.DB) *WalletService {
+	return &WalletService{db: db}
+}
+
+func (s *WalletService) Create(ctx context.Context, ownerID, name string) (*model.Wallet, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wallet := model.Wallet{Name: name, Role: WalletOwner}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO wallets (name, owner_id) VALUES ($1, $2) RETURNING id, created_at`,
+		name, ownerID,
+	).Scan(&wallet.ID, &wallet.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert wallet: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO wallet_members (wallet_id, user_id, role, can_withdraw) VALUES ($1, $2, $3, TRUE)`,
+		wallet.ID, ownerID, WalletOwner,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert wallet member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &wallet, nil
+}
+
+const walletColumns = `w.id, w.name, m.role, w.current_balance, w.withdrawn, w.held, m.route_accruals, w.created_at`
+
+func scanWallet(row interface{ Scan(...any) error }) (model.Wallet, error) {
+	var w model.Wallet
+	err := row.Scan(&w.ID, &w.Name, &w.Role, &w.Current, &w.Withdrawn, &w.Held, &w.Routed, &w.CreatedAt)
+	return w, err
+}
+
+func (s *WalletService) ListByUser(ctx context.Context, userID string) ([]model.Wallet, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+walletColumns+`
+		FROM wallets w
+		JOIN wallet_members m ON m.wallet_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.created_at ASC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query wallets: %w", err)
+	}
+	defer rows.Close()
+
+	var wallets []model.Wallet
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan wallet: %w", err)
+		}
+		wallets = append(wallets, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return wallets, nil
+}
+
+// Get returns the wallet with its members. Non-members get ErrWalletNotFound.
+func (s *WalletService) Get(ctx context.Context, userID, walletID string) (*model.Wallet, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+walletColumns+`
+		FROM wallets w
+		JOIN wallet_members m ON m.wallet_id = w.id
+		WHERE w.id = $1 AND m.user_id = $2
+	`, walletID, userID)
+	wallet, err := scanWallet(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, fmt.Errorf("get wallet: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.login, m.role, m.can_withdraw, m.max_withdrawal, m.route_accruals, m.joined_at
+		FROM wallet_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.wallet_id = $1
+		ORDER BY m.joined_at ASC
+	`, walletID)
+	if err != nil {
+		return nil, fmt.Errorf("query wallet members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m model.WalletMember
+		if err := rows.Scan(&m.Login, &m.Role, &m.CanWithdraw, &m.MaxWithdrawal, &m.RouteAccruals, &m.JoinedAt); err != nil {
+			return nil, fmt.Errorf("scan wallet member: %w", err)
+		}
+		wallet.Members = append(wallet.Members, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return &wallet, nil
+}
+
+func (s *WalletService) Invite(ctx context.Context, ownerID, walletID, login string) (*model.WalletInvite, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireWalletOwner(ctx, tx, walletID, ownerID); err != nil {
+		return nil, err
+	}
+
+	var inviteeID string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE login = $1`, login).Scan(&inviteeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWalletMemberNotFound
+		}
+		return nil, fmt.Errorf("get invitee: %w", err)
+	}
+
+	var member bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM wallet_members WHERE wallet_id = $1 AND user_id = $2)`,
+		walletID, inviteeID,
+	).Scan(&member)
+	if err != nil {
+		return nil, fmt.Errorf("check wallet member: %w", err)
+	}
+	if member {
+		return nil, ErrWalletMemberExists
+	}
+
+	invite := model.WalletInvite{WalletID: walletID, Status: InvitePending}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO wallet_invites (wallet_id, inviter_id, invitee_id, status)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (wallet_id, invitee_id) WHERE status = 'PENDING' DO UPDATE SET created_at = NOW()
+		RETURNING id, created_at,
+		          (SELECT name FROM wallets WHERE id = $1),
+		          (SELECT login FROM users WHERE id = $2)
+	`, walletID, ownerID, inviteeID, InvitePending).Scan(&invite.ID, &invite.CreatedAt, &invite.WalletName, &invite.From)
+	if err != nil {
+		return nil, fmt.Errorf("insert wallet invite: %w", err)
+	}
+
+	message := fmt.Sprintf("%s invited you to the wallet %s", invite.From, invite.WalletName)
+	if err := notify(ctx, tx, inviteeID, "WALLET_INVITE", message); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &invite, nil
+}
+
+func (s *WalletService) ListInvites(ctx context.Context, userID string) ([]model.WalletInvite, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT i.id, i.wallet_id, w.name, u.login, i.status, i.created_at
+		FROM wallet_invites i
+		JOIN wallets w ON w.id = i.wallet_id
+		JOIN users u ON u.id = i.inviter_id
+		WHERE i.invitee_id = $1 AND i.status = $2
+		ORDER BY i.created_at DESC
+	`, userID, InvitePending)
+	if err != nil {
+		return nil, fmt.Errorf("query wallet invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []model.WalletInvite
+	for rows.Next() {
+		var i model.WalletInvite
+		if err := rows.Scan(&i.ID, &i.WalletID, &i.WalletName, &i.From, &i.Status, &i.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan wallet invite: %w", err)
+		}
+		invites = append(invites, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return invites, nil
+}
+
+// RespondInvite accepts or declines a pending invite. New members cannot
+// withdraw until the owner grants it.
+func (s *WalletService) RespondInvite(ctx context.Context, userID, inviteID string, accept bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	status := InviteDeclined
+	if accept {
+		status = InviteAccepted
+	}
+
+	var walletID string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE wallet_invites SET status = $3, responded_at = NOW()
+		WHERE id = $1 AND invitee_id = $2 AND status = 'PENDING'
+		RETURNING wallet_id
+	`, inviteID, userID, status).Scan(&walletID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return ErrWalletInviteNotFound
+		}
+		return fmt.Errorf("update wallet invite: %w", err)
+	}
+
+	if accept {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO wallet_members (wallet_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			walletID, userID, WalletMember,
+		)
+		if err != nil {
+			return fmt.Errorf("insert wallet member: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *WalletService) UpdateMember(ctx context.Context, ownerID, walletID, login string, perms WalletPermissions) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireWalletOwner(ctx, tx, walletID, ownerID); err != nil {
+		return err
+	}
+
+	var role string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE wallet_members m SET can_withdraw = $3, max_withdrawal = $4
+		FROM users u
+		WHERE m.wallet_id = $1 AND m.user_id = u.id AND u.login = $2
+		RETURNING m.role
+	`, walletID, login, perms.CanWithdraw, perms.MaxWithdrawal).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWalletMemberNotFound
+		}
+		return fmt.Errorf("update wallet member: %w", err)
+	}
+	if role == WalletOwner {
+		return ErrWalletOwner
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember lets the owner remove a member or a member leave on their
+// own. Points they routed stay in the wallet.
+func (s *WalletService) RemoveMember(ctx context.Context, userID, walletID, login string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var memberID, role string
+	err = tx.QueryRowContext(ctx, `
+		SELECT m.user_id, m.role
+		FROM wallet_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.wallet_id = $1 AND u.login = $2
+		FOR UPDATE OF m
+	`, walletID, login).Scan(&memberID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return ErrWalletMemberNotFound
+		}
+		return fmt.Errorf("get wallet member: %w", err)
+	}
+	if role == WalletOwner {
+		return ErrWalletOwner
+	}
+	if memberID != userID {
+		if err := requireWalletOwner(ctx, tx, walletID, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM wallet_members WHERE wallet_id = $1 AND user_id = $2`, walletID, memberID)
+	if err != nil {
+		return fmt.Errorf("delete wallet member: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// SetRouting sends the member's future accruals to the wallet, or back to
+// their own balance. Enabling it moves routing away from any other wallet.
+func (s *WalletService) SetRouting(ctx context.Context, userID, walletID string, enabled bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := walletRole(ctx, tx, walletID, userID); err != nil {
+		return err
+	}
+
+	if enabled {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE wallet_members SET route_accruals = FALSE WHERE user_id = $1 AND wallet_id <> $2 AND route_accruals`,
+			userID, walletID,
+		)
+		if err != nil {
+			return fmt.Errorf("update wallet routing: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE wallet_members SET route_accruals = $3 WHERE wallet_id = $1 AND user_id = $2`,
+		walletID, userID, enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("update wallet routing: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func walletRole(ctx context.Context, q queryer, walletID, userID string) (string, error) {
+	var role string
+	err := q.QueryRowContext(ctx,
+		`SELECT role FROM wallet_members WHERE wallet_id = $1 AND user_id = $2`,
+		walletID, userID,
+	).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return "", ErrWalletNotFound
+		}
+		return "", fmt.Errorf("get wallet member: %w", err)
+	}
+	return role, nil
+}
+
+func requireWalletOwner(ctx context.Context, q queryer, walletID, userID string) error {
+	role, err := walletRole(ctx, q, walletID, userID)
+	if err != nil {
+		return err
+	}
+	if role != WalletOwner {
+		return ErrWalletForbidden
+	}
+	return nil
+}
+
+// checkWalletWithdrawal verifies the member may take sum out of the wallet.
+// It locks the member's user row first, matching the lock order of
+// personal withdrawals and accruals.
+func checkWalletWithdrawal(ctx context.Context, tx *sql.Tx, walletID, userID string, sum float64) error {
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return fmt.Errorf("lock user: %w", err)
+	}
+
+	var perms WalletPermissions
+	err := tx.QueryRowContext(ctx,
+		`SELECT can_withdraw, max_withdrawal FROM wallet_members WHERE wallet_id = $1 AND user_id = $2 FOR SHARE`,
+		walletID, userID,
+	).Scan(&perms.CanWithdraw, &perms.MaxWithdrawal)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return ErrWalletNotFound
+		}
+		return fmt.Errorf("get wallet member: %w", err)
+	}
+
+	if !perms.CanWithdraw {
+		return ErrWalletForbidden
+	}
+	if perms.MaxWithdrawal > 0 && sum > perms.MaxWithdrawal {
+		return fmt.Errorf("%w: maximum withdrawal is %.2f", ErrWalletForbidden, perms.MaxWithdrawal)
+	}
+	return nil
+}
+
+// routeAccrual credits an accrual to the wallet the user routes to, if any.
+// The caller must already hold the user's row lock.
+func routeAccrual(ctx context.Context, tx *sql.Tx, userID, orderNumber string, accrual float64) (bool, error) {
+	var walletID string
+	err := tx.QueryRowContext(ctx,
+		`SELECT wallet_id FROM wallet_members WHERE user_id = $1 AND route_accruals`,
+		userID,
+	).Scan(&walletID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("get accrual wallet: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE wallets SET current_balance = current_balance + $1 WHERE id = $2`, accrual, walletID)
+	if err != nil {
+		return false, fmt.Errorf("update wallet balance: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET wallet_id = $1 WHERE number = $2`, walletID, orderNumber)
+	if err != nil {
+		return false, fmt.Errorf("update order: %w", err)
+	}
+
+	return true, nil
+}
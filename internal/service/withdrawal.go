@@ -5,58 +5,472 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"gophermart/internal/model"
 )
 
+const (
+	WithdrawalReserved  = "RESERVED"
+	WithdrawalConfirmed = "CONFIRMED"
+	WithdrawalCancelled = "CANCELLED"
+	WithdrawalExpired   = "EXPIRED"
+
+	WithdrawalPendingApproval = "PENDING_APPROVAL"
+	WithdrawalRejected        = "REJECTED"
+
+	WithdrawalTypeOrder   = "ORDER"
+	WithdrawalTypeCashout = "CASHOUT"
+	WithdrawalTypeReward  = "REWARD"
+	WithdrawalTypeInStore = "IN_STORE"
+)
+
+var (
+	ErrInsufficientFunds         = errors.New("insufficient funds")
+	ErrWithdrawalNotFound        = errors.New("withdrawal not found")
+	ErrWithdrawalState           = errors.New("withdrawal is not in a suitable state")
+	ErrHoldExpired               = errors.New("withdrawal hold expired")
+	ErrReversalExceedsWithdrawal = errors.New("reversal exceeds withdrawn sum")
+	ErrWithdrawalOrderUsed       = errors.New("order already paid with points")
+	ErrWithdrawalOrderForeign    = errors.New("order belongs to another user")
+)
+
+// WithdrawalOrderPolicy controls which order numbers may be paid with points.
+// UniqueOrder allows one active withdrawal per order number; a fully
+// reversed or released withdrawal frees the number again. RejectForeignOrder
+// refuses orders uploaded by a different user.
+type WithdrawalOrderPolicy struct {
+	UniqueOrder        bool
+	RejectForeignOrder bool
+}
+
+const withdrawalColumns = `id, user_id, order_number, sum, refunded, status, expires_at, review_reason,
+	type, destination, payout_status, processed_at, COALESCE(wallet_id::text, '')`
+
 type WithdrawalService struct {
-	db *sql.DB
+	db                *sql.DB
+	limits            WithdrawalLimits
+	orderPolicy       WithdrawalOrderPolicy
+	approvalThreshold float64
 }
 
-func NewWithdrawalService(db *sql.DB) *WithdrawalService {
-	return &WithdrawalService{db: db}
+// NewWithdrawalService creates the service. A zero approvalThreshold
+// disables manual approval.
+func NewWithdrawalService(db *sql.DB, limits WithdrawalLimits, orderPolicy WithdrawalOrderPolicy, approvalThreshold float64) *WithdrawalService {
+	return &WithdrawalService{
+		db:                db,
+		limits:            limits,
+		orderPolicy:       orderPolicy,
+		approvalThreshold: approvalThreshold,
+	}
 }
 
-func (s *WithdrawalService) Create(ctx context.Context, userID, orderNumber string, sum float64) error {
+// Create debits sum right away. Withdrawals above the approval threshold
+// are held in PENDING_APPROVAL until an admin approves or rejects them.
+func (s *WithdrawalService) Create(ctx context.Context, userID, orderNumber string, sum float64) (*model.Withdrawal, error) {
+	return s.create(ctx, model.Withdrawal{
+		UserID:      userID,
+		OrderNumber: orderNumber,
+		Sum:         sum,
+		Type:        WithdrawalTypeOrder,
+	})
+}
+
+// CreateFromWallet pays an order from a shared wallet. The member's own
+// withdrawal limits still apply on top of their wallet permissions.
+func (s *WithdrawalService) CreateFromWallet(ctx context.Context, userID, walletID, orderNumber string, sum float64) (*model.Withdrawal, error) {
+	return s.create(ctx, model.Withdrawal{
+		UserID:      userID,
+		WalletID:    walletID,
+		OrderNumber: orderNumber,
+		Sum:         sum,
+		Type:        WithdrawalTypeOrder,
+	})
+}
+
+func (s *WithdrawalService) create(ctx context.Context, req model.Withdrawal) (*model.Withdrawal, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return nil, fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
-	var current float64
-	err = tx.QueryRowContext(ctx, `SELECT COALESCE(current_balance, 0) FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&current)
+	wd, err := s.createTx(ctx, tx, req)
 	if err != nil {
-		return fmt.Errorf("get balance: %w", err)
+		return nil, err
 	}
 
-	if current < sum {
-		return errors.New("insufficient funds")
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
 	}
 
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO withdrawals (user_id, order_number, sum, processed_at) VALUES ($1, $2, $3, $4)`,
-		userID, orderNumber, sum, time.Now(),
+	return wd, nil
+}
+
+// createTx runs the withdrawal within the caller's transaction so other
+// writes can commit or fail together with it.
+func (s *WithdrawalService) createTx(ctx context.Context, tx *sql.Tx, req model.Withdrawal) (*model.Withdrawal, error) {
+	if req.Type == WithdrawalTypeOrder {
+		if err := s.checkOrder(ctx, tx, req.UserID, req.OrderNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	acc := accountOf(&req)
+	if req.WalletID != "" {
+		if err := checkWalletWithdrawal(ctx, tx, req.WalletID, req.UserID, req.Sum); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if err := debitAccount(ctx, tx, acc, req.Sum); err != nil {
+		return nil, err
+	}
+	if err := s.limits.check(ctx, tx, req.UserID, req.Sum, now); err != nil {
+		return nil, err
+	}
+
+	status := WithdrawalConfirmed
+	balanceQuery := `UPDATE ` + acc.table + ` SET withdrawn = withdrawn + $1 WHERE id = $2`
+	if s.needsApproval(req.Sum) {
+		status = WithdrawalPendingApproval
+		balanceQuery = `UPDATE ` + acc.table + ` SET held = held + $1 WHERE id = $2`
+	}
+
+	var walletID any
+	if req.WalletID != "" {
+		walletID = req.WalletID
+	}
+
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO withdrawals (user_id, wallet_id, order_number, sum, status, type, destination, payout_status, created_at, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		RETURNING `+withdrawalColumns,
+		req.UserID, walletID, req.OrderNumber, req.Sum, status, req.Type, req.Destination, req.PayoutStatus, now,
+	)
+	wd, err := scanWithdrawal(row)
+	if err != nil {
+		if isUniqueViolation(err, "uq_withdrawals_active_order") {
+			return nil, ErrWithdrawalOrderUsed
+		}
+		return nil, fmt.Errorf("insert withdrawal: %w", err)
+	}
+
+	if !acc.isWallet() {
+		if err := consumeLots(ctx, tx, req.UserID, wd.ID, req.Sum); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, balanceQuery, req.Sum, acc.id)
+	if err != nil {
+		return nil, fmt.Errorf("update balance: %w", err)
+	}
+
+	return &wd, nil
+}
+
+// Reserve holds sum on the user's balance until the withdrawal is confirmed,
+// cancelled, or the hold expires after ttl.
+func (s *WithdrawalService) Reserve(ctx context.Context, userID, orderNumber string, sum float64, ttl time.Duration) (*model.Withdrawal, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := s.reserveTx(ctx, tx, model.Withdrawal{
+		UserID:      userID,
+		OrderNumber: orderNumber,
+		Sum:         sum,
+		Type:        WithdrawalTypeOrder,
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return wd, nil
+}
+
+func (s *WithdrawalService) reserveTx(ctx context.Context, tx *sql.Tx, req model.Withdrawal, ttl time.Duration) (*model.Withdrawal, error) {
+	if req.Type == WithdrawalTypeOrder {
+		if err := s.checkOrder(ctx, tx, req.UserID, req.OrderNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	// in-store codes are settled by the merchant on the spot and can't wait
+	// for a review
+	if req.Type == WithdrawalTypeInStore && s.needsApproval(req.Sum) {
+		return nil, fmt.Errorf("%w: in-store spending above %.2f needs approval, withdraw directly instead", ErrWithdrawalLimit, s.approvalThreshold)
+	}
+
+	now := time.Now()
+	if err := debitBalance(ctx, tx, req.UserID, req.Sum); err != nil {
+		return nil, err
+	}
+	if err := s.limits.check(ctx, tx, req.UserID, req.Sum, now); err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(ttl)
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO withdrawals (user_id, order_number, sum, status, type, expires_at, created_at, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING `+withdrawalColumns,
+		req.UserID, req.OrderNumber, req.Sum, WithdrawalReserved, req.Type, expiresAt, now,
 	)
+	wd, err := scanWithdrawal(row)
+	if err != nil {
+		if isUniqueViolation(err, "uq_withdrawals_active_order") {
+			return nil, ErrWithdrawalOrderUsed
+		}
+		return nil, fmt.Errorf("insert withdrawal: %w", err)
+	}
+
+	if err := consumeLots(ctx, tx, req.UserID, wd.ID, req.Sum); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET held = held + $1 WHERE id = $2`, req.Sum, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("update balance: %w", err)
+	}
+
+	return &wd, nil
+}
+
+// Confirm settles a reservation. Reservations above the approval threshold
+// go to PENDING_APPROVAL instead, like direct withdrawals do.
+func (s *WithdrawalService) Confirm(ctx context.Context, userID, withdrawalID string) (*model.Withdrawal, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := lockWithdrawal(ctx, tx, withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	if wd.UserID != userID {
+		return nil, ErrWithdrawalNotFound
+	}
+	// in-store holds are only settled by the merchant redeeming the code
+	if wd.Status != WithdrawalReserved || wd.Type == WithdrawalTypeInStore {
+		return nil, ErrWithdrawalState
+	}
+	if wd.ExpiresAt != nil && !wd.ExpiresAt.After(time.Now()) {
+		return nil, ErrHoldExpired
+	}
+
+	if s.needsApproval(wd.Sum) {
+		// the points stay held until an admin reviews the withdrawal
+		_, err = tx.ExecContext(ctx,
+			`UPDATE withdrawals SET status = $1, expires_at = NULL WHERE id = $2`,
+			WithdrawalPendingApproval, wd.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("update withdrawal: %w", err)
+		}
+		wd.Status = WithdrawalPendingApproval
+		wd.ExpiresAt = nil
+	} else if err := settleHold(ctx, tx, &wd); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &wd, nil
+}
+
+func (s *WithdrawalService) needsApproval(sum float64) bool {
+	return s.approvalThreshold > 0 && sum > s.approvalThreshold
+}
+
+func (s *WithdrawalService) Cancel(ctx context.Context, userID, withdrawalID string) (*model.Withdrawal, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := lockWithdrawal(ctx, tx, withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	if wd.UserID != userID {
+		return nil, ErrWithdrawalNotFound
+	}
+	if wd.Status != WithdrawalReserved {
+		return nil, ErrWithdrawalState
+	}
+
+	if err := releaseHold(ctx, tx, &wd, WithdrawalCancelled); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &wd, nil
+}
+
+// ExpireHolds releases reservations whose hold ran out before now and
+// returns how many were expired.
+func (s *WithdrawalService) ExpireHolds(ctx context.Context, now time.Time, limit int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM withdrawals
+		WHERE status = $1 AND expires_at <= $2
+		ORDER BY expires_at ASC
+		LIMIT $3
+	`, WithdrawalReserved, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("query expired holds: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan withdrawal id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	expired := 0
+	var errs []error
+	for _, id := range ids {
+		ok, err := s.expireHold(ctx, id, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("expire hold %s: %w", id, err))
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	return expired, errors.Join(errs...)
+}
+
+func (s *WithdrawalService) expireHold(ctx context.Context, withdrawalID string, now time.Time) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := lockWithdrawal(ctx, tx, withdrawalID)
 	if err != nil {
-		return fmt.Errorf("insert withdrawal: %w", err)
+		return false, err
+	}
+	// confirmed or cancelled since it was selected
+	if wd.Status != WithdrawalReserved || wd.ExpiresAt == nil || wd.ExpiresAt.After(now) {
+		return false, nil
+	}
+
+	if err := releaseHold(ctx, tx, &wd, WithdrawalExpired); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (s *WithdrawalService) Approve(ctx context.Context, withdrawalID, reason string) (*model.Withdrawal, error) {
+	return s.review(ctx, withdrawalID, reason, true)
+}
+
+func (s *WithdrawalService) Reject(ctx context.Context, withdrawalID, reason string) (*model.Withdrawal, error) {
+	return s.review(ctx, withdrawalID, reason, false)
+}
+
+func (s *WithdrawalService) review(ctx context.Context, withdrawalID, reason string, approve bool) (*model.Withdrawal, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := lockWithdrawal(ctx, tx, withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	if wd.Status != WithdrawalPendingApproval {
+		return nil, ErrWithdrawalState
+	}
+
+	if approve {
+		err = settleHold(ctx, tx, &wd)
+	} else {
+		err = releaseHold(ctx, tx, &wd, WithdrawalRejected)
+		if err == nil && wd.Type == WithdrawalTypeReward {
+			err = restockReward(ctx, tx, wd.ID)
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	_, err = tx.ExecContext(ctx,
-		`UPDATE users SET current_balance = current_balance - $1, withdrawn = withdrawn + $1 WHERE id = $2`,
-		sum, userID,
+		`UPDATE withdrawals SET review_reason = $1, reviewed_at = NOW() WHERE id = $2`,
+		reason, wd.ID,
 	)
 	if err != nil {
-		return fmt.Errorf("update balance: %w", err)
+		return nil, fmt.Errorf("update withdrawal: %w", err)
+	}
+	wd.ReviewReason = reason
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return &wd, nil
+}
+
+func (s *WithdrawalService) ListByStatus(ctx context.Context, status string, limit int) ([]model.Withdrawal, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+withdrawalColumns+` FROM withdrawals WHERE status = $1 ORDER BY created_at ASC LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query withdrawals: %w", err)
+	}
+	defer rows.Close()
+
+	withdrawals := []model.Withdrawal{}
+	for rows.Next() {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan withdrawal: %w", err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
 	}
 
-	return tx.Commit()
+	return withdrawals, nil
 }
 
 func (s *WithdrawalService) ListByUser(ctx context.Context, userID string) ([]model.Withdrawal, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, user_id, order_number, sum, processed_at FROM withdrawals WHERE user_id = $1 ORDER BY processed_at DESC`,
+		`SELECT `+withdrawalColumns+` FROM withdrawals WHERE user_id = $1 ORDER BY processed_at DESC`,
 		userID,
 	)
 	if err != nil {
@@ -66,8 +480,8 @@ func (s *WithdrawalService) ListByUser(ctx context.Context, userID string) ([]mo
 
 	var withdrawals []model.Withdrawal
 	for rows.Next() {
-		var w model.Withdrawal
-		if err := rows.Scan(&w.ID, &w.UserID, &w.OrderNumber, &w.Sum, &w.ProcessedAt); err != nil {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan withdrawal: %w", err)
 		}
 		withdrawals = append(withdrawals, w)
@@ -79,3 +493,292 @@ func (s *WithdrawalService) ListByUser(ctx context.Context, userID string) ([]mo
 
 	return withdrawals, nil
 }
+
+func (s *WithdrawalService) StreamByUser(ctx context.Context, userID string, period Period, fn func(model.Withdrawal) error) error {
+	from, to := period.args()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+withdrawalColumns+`
+		FROM withdrawals
+		WHERE user_id = $1
+		  AND ($2::timestamptz IS NULL OR processed_at >= $2)
+		  AND ($3::timestamptz IS NULL OR processed_at < $3)
+		ORDER BY processed_at ASC
+	`, userID, from, to)
+	if err != nil {
+		return fmt.Errorf("query withdrawals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
+			return fmt.Errorf("scan withdrawal: %w", err)
+		}
+		if err := fn(w); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return nil
+}
+
+// Reverse gives back part or all of a withdrawal. A zero sum refunds
+// whatever has not been refunded yet. Cash-outs can't be reversed while the
+// money may still be paid out; a failed payout is refunded by
+// PayoutService.Apply.
+func (s *WithdrawalService) Reverse(ctx context.Context, withdrawalID string, sum float64, reason string) (*model.WithdrawalReversal, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	wd, err := lockWithdrawal(ctx, tx, withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	if wd.Type == WithdrawalTypeCashout && wd.PayoutStatus != PayoutFailed {
+		return nil, ErrWithdrawalState
+	}
+
+	rev, err := reverseLocked(ctx, tx, &wd, sum, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return rev, nil
+}
+
+// reverseLocked records a reversal of wd, which the caller must have locked
+// with lockWithdrawal in tx.
+func reverseLocked(ctx context.Context, tx *sql.Tx, wd *model.Withdrawal, sum float64, reason string) (*model.WithdrawalReversal, error) {
+	if wd.Status != WithdrawalConfirmed {
+		return nil, ErrWithdrawalState
+	}
+
+	if sum == 0 {
+		sum = math.Round((wd.Sum-wd.Refunded)*100) / 100
+	}
+	if sum <= 0 {
+		return nil, ErrReversalExceedsWithdrawal
+	}
+
+	// the remaining sum is checked in NUMERIC, float64 can't represent
+	// cents exactly
+	var refunded float64
+	err := tx.QueryRowContext(ctx,
+		`UPDATE withdrawals SET refunded = refunded + $1 WHERE id = $2 AND sum - refunded >= $1 RETURNING refunded`,
+		sum, wd.ID,
+	).Scan(&refunded)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReversalExceedsWithdrawal
+		}
+		return nil, fmt.Errorf("update withdrawal: %w", err)
+	}
+
+	rev := model.WithdrawalReversal{WithdrawalID: wd.ID, Sum: sum, Reason: reason}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO withdrawal_reversals (withdrawal_id, user_id, sum, reason) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		wd.ID, wd.UserID, sum, reason,
+	).Scan(&rev.ID, &rev.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert reversal: %w", err)
+	}
+
+	acc := accountOf(wd)
+	if !acc.isWallet() {
+		if err := restoreLots(ctx, tx, wd.UserID, wd.ID, sum); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE `+acc.table+` SET current_balance = current_balance + $1, withdrawn = withdrawn - $1 WHERE id = $2`,
+		sum, acc.id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update balance: %w", err)
+	}
+
+	wd.Refunded = refunded
+	wd.ReversalStatus = reversalStatus(wd.Sum, wd.Refunded)
+
+	return &rev, nil
+}
+
+func reversalStatus(sum, refunded float64) string {
+	switch {
+	case refunded <= 0:
+		return ""
+	case refunded >= sum:
+		return "REVERSED"
+	default:
+		return "PARTIALLY_REVERSED"
+	}
+}
+
+func (s *WithdrawalService) checkOrder(ctx context.Context, tx *sql.Tx, userID, orderNumber string) error {
+	if s.orderPolicy.RejectForeignOrder {
+		var ownerID string
+		err := tx.QueryRowContext(ctx, `SELECT user_id FROM orders WHERE number = $1`, orderNumber).Scan(&ownerID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("check order owner: %w", err)
+		}
+		if err == nil && ownerID != userID {
+			return ErrWithdrawalOrderForeign
+		}
+	}
+
+	if s.orderPolicy.UniqueOrder {
+		var used bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(
+				SELECT 1 FROM withdrawals
+				WHERE order_number = $1 AND status IN ('RESERVED', 'CONFIRMED', 'PENDING_APPROVAL') AND refunded < sum
+			)
+		`, orderNumber).Scan(&used)
+		if err != nil {
+			return fmt.Errorf("check order withdrawals: %w", err)
+		}
+		if used {
+			return ErrWithdrawalOrderUsed
+		}
+	}
+
+	return nil
+}
+
+func scanWithdrawal(row interface{ Scan(...any) error }) (model.Withdrawal, error) {
+	var w model.Withdrawal
+	var expiresAt sql.NullTime
+	if err := row.Scan(&w.ID, &w.UserID, &w.OrderNumber, &w.Sum, &w.Refunded, &w.Status, &expiresAt, &w.ReviewReason,
+		&w.Type, &w.Destination, &w.PayoutStatus, &w.ProcessedAt, &w.WalletID); err != nil {
+		return w, err
+	}
+	if expiresAt.Valid && w.Status == WithdrawalReserved {
+		w.ExpiresAt = &expiresAt.Time
+	}
+	w.ReversalStatus = reversalStatus(w.Sum, w.Refunded)
+	return w, nil
+}
+
+func lockWithdrawal(ctx context.Context, tx *sql.Tx, withdrawalID string) (model.Withdrawal, error) {
+	row := tx.QueryRowContext(ctx, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE id = $1 FOR UPDATE`, withdrawalID)
+	wd, err := scanWithdrawal(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || isInvalidText(err) {
+			return wd, ErrWithdrawalNotFound
+		}
+		return wd, fmt.Errorf("get withdrawal: %w", err)
+	}
+	return wd, nil
+}
+
+// account is the balance a withdrawal draws from: a user's own balance or
+// a shared wallet. Both tables carry current_balance, withdrawn and held.
+// Wallet balances are not tracked in accrual lots and never expire.
+type account struct {
+	table string
+	id    string
+}
+
+func accountOf(wd *model.Withdrawal) account {
+	if wd.WalletID != "" {
+		return account{table: "wallets", id: wd.WalletID}
+	}
+	return account{table: "users", id: wd.UserID}
+}
+
+func (a account) isWallet() bool {
+	return a.table == "wallets"
+}
+
+// debitBalance locks the user row and takes sum off the spendable balance.
+func debitBalance(ctx context.Context, tx *sql.Tx, userID string, sum float64) error {
+	return debitAccount(ctx, tx, account{table: "users", id: userID}, sum)
+}
+
+func debitAccount(ctx context.Context, tx *sql.Tx, acc account, sum float64) error {
+	var current float64
+	err := tx.QueryRowContext(ctx, `SELECT COALESCE(current_balance, 0) FROM `+acc.table+` WHERE id = $1 FOR UPDATE`, acc.id).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("get balance: %w", err)
+	}
+
+	if current < sum {
+		return ErrInsufficientFunds
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE `+acc.table+` SET current_balance = current_balance - $1 WHERE id = $2`, sum, acc.id)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+	return nil
+}
+
+// settleHold turns held funds of a reserved withdrawal into withdrawn ones.
+func settleHold(ctx context.Context, tx *sql.Tx, wd *model.Withdrawal) error {
+	now := time.Now()
+	_, err := tx.ExecContext(ctx,
+		`UPDATE withdrawals SET status = $1, processed_at = $2 WHERE id = $3`,
+		WithdrawalConfirmed, now, wd.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update withdrawal: %w", err)
+	}
+
+	acc := accountOf(wd)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE `+acc.table+` SET held = held - $1, withdrawn = withdrawn + $1 WHERE id = $2`,
+		wd.Sum, acc.id,
+	)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+
+	wd.Status = WithdrawalConfirmed
+	wd.ProcessedAt = now
+	wd.ExpiresAt = nil
+	return nil
+}
+
+// releaseHold returns held funds to the spendable balance and closes the
+// withdrawal with the given status.
+func releaseHold(ctx context.Context, tx *sql.Tx, wd *model.Withdrawal, status string) error {
+	now := time.Now()
+	_, err := tx.ExecContext(ctx,
+		`UPDATE withdrawals SET status = $1, released_at = $2 WHERE id = $3`,
+		status, now, wd.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update withdrawal: %w", err)
+	}
+
+	acc := accountOf(wd)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE `+acc.table+` SET held = held - $1, current_balance = current_balance + $1 WHERE id = $2`,
+		wd.Sum, acc.id,
+	)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+
+	if !acc.isWallet() {
+		if err := restoreLots(ctx, tx, wd.UserID, wd.ID, wd.Sum); err != nil {
+			return err
+		}
+	}
+
+	wd.Status = status
+	wd.ExpiresAt = nil
+	return nil
+}
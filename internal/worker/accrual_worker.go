@@ -65,25 +65,7 @@ func (w *AccrualWorker) processBatch(ctx context.Context) error {
 			continue
 		}
 
-		status := resp.Status
-		var accrual *float64
-		if status == "PROCESSED" || status == "INVALID" {
-			switch status {
-			case "PROCESSED":
-				if resp.Accrual > 0 {
-					accrual = &resp.Accrual
-					status = "PROCESSED"
-				} else {
-					status = "INVALID"
-				}
-			case "INVALID":
-				status = "INVALID"
-			case "REGISTERED", "PROCESSING":
-				status = "PROCESSING"
-			}
-		} else if status == "REGISTERED" || status == "PROCESSING" {
-			status = "PROCESSING"
-		}
+		status, accrual := resp.OrderUpdate()
 
 		if err := w.orderSvc.UpdateStatus(ctx, order.Number, status, accrual); err != nil {
 			slog.Error("failed to update order status", "order", order.Number, "error", err)
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gophermart/internal/service"
+)
+
+// ExpiryWorker expires points once a night at runHour UTC.
+type ExpiryWorker struct {
+	expirySvc *service.ExpiryService
+	runHour   int
+	batchSize int
+}
+
+func NewExpiryWorker(expirySvc *service.ExpiryService) *ExpiryWorker {
+	return &ExpiryWorker{
+		expirySvc: expirySvc,
+		runHour:   3,
+		batchSize: 500,
+	}
+}
+
+func (w *ExpiryWorker) Start(ctx context.Context) {
+	slog.Info("starting expiry worker")
+
+	for {
+		timer := time.NewTimer(time.Until(w.nextRun(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("expiry worker stopped")
+			return
+		case <-timer.C:
+			w.run(ctx)
+		}
+	}
+}
+
+func (w *ExpiryWorker) nextRun(now time.Time) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), w.runHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (w *ExpiryWorker) run(ctx context.Context) {
+	now := time.Now()
+	total := 0
+	var cursor *service.ExpiryCursor
+	for {
+		n, next, err := w.expirySvc.ExpireDue(ctx, now, cursor, w.batchSize)
+		total += n
+		if err != nil {
+			slog.Error("expiring points failed", "error", err)
+		}
+		// a full batch moves the cursor on even when some of its lots failed
+		if next == nil || ctx.Err() != nil {
+			break
+		}
+		cursor = next
+	}
+	slog.Info("points expiry finished", "lots", total)
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gophermart/internal/service"
+)
+
+type HoldSweeper struct {
+	withdrawalSvc *service.WithdrawalService
+	interval      time.Duration
+	batchSize     int
+}
+
+func NewHoldSweeper(withdrawalSvc *service.WithdrawalService) *HoldSweeper {
+	return &HoldSweeper{
+		withdrawalSvc: withdrawalSvc,
+		interval:      time.Minute,
+		batchSize:     100,
+	}
+}
+
+func (s *HoldSweeper) Start(ctx context.Context) {
+	slog.Info("starting hold sweeper")
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("hold sweeper stopped")
+			return
+		case <-ticker.C:
+			n, err := s.withdrawalSvc.ExpireHolds(ctx, time.Now(), s.batchSize)
+			if err != nil {
+				slog.Error("expiring holds failed", "error", err)
+			}
+			if n > 0 {
+				slog.Info("expired withdrawal holds", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gophermart/internal/service"
+)
+
+type PayoutWorker struct {
+	payoutSvc *service.PayoutService
+	interval  time.Duration
+	batchSize int
+}
+
+func NewPayoutWorker(payoutSvc *service.PayoutService) *PayoutWorker {
+	return &PayoutWorker{
+		payoutSvc: payoutSvc,
+		interval:  30 * time.Second,
+		batchSize: 20,
+	}
+}
+
+func (w *PayoutWorker) Start(ctx context.Context) {
+	slog.Info("starting payout worker")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("payout worker stopped")
+			return
+		case <-ticker.C:
+			n, err := w.payoutSvc.SubmitNew(ctx, w.batchSize)
+			if err != nil {
+				slog.Error("submitting payouts failed", "error", err)
+			}
+			if n > 0 {
+				slog.Info("payouts submitted", "count", n)
+			}
+
+			n, err = w.payoutSvc.PollPending(ctx, w.batchSize)
+			if err != nil {
+				slog.Error("polling payouts failed", "error", err)
+			}
+			if n > 0 {
+				slog.Info("payouts settled", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gophermart/internal/service"
+)
+
+type ScheduleWorker struct {
+	scheduleSvc *service.ScheduleService
+	interval    time.Duration
+	batchSize   int
+}
+
+func NewScheduleWorker(scheduleSvc *service.ScheduleService) *ScheduleWorker {
+	return &ScheduleWorker{
+		scheduleSvc: scheduleSvc,
+		interval:    time.Minute,
+		batchSize:   50,
+	}
+}
+
+func (w *ScheduleWorker) Start(ctx context.Context) {
+	slog.Info("starting schedule worker")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("schedule worker stopped")
+			return
+		case <-ticker.C:
+			n, err := w.scheduleSvc.RunDue(ctx, time.Now(), w.batchSize)
+			if err != nil {
+				slog.Error("running schedules failed", "error", err)
+			}
+			if n > 0 {
+				slog.Info("scheduled withdrawals executed", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_orders_user_uploaded ON orders(user_id, uploaded_at);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_user_processed ON withdrawals(user_id, processed_at);
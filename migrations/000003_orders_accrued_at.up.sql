@@ -0,0 +1 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS accrued_at TIMESTAMPTZ;
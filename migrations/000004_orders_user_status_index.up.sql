@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_orders_user_status ON orders(user_id, status);
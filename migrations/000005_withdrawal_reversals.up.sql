@@ -0,0 +1,13 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS refunded NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS withdrawal_reversals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    withdrawal_id UUID NOT NULL REFERENCES withdrawals(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sum NUMERIC(10,2) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdrawal_reversals_withdrawal_id ON withdrawal_reversals(withdrawal_id);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_reversals_user_id ON withdrawal_reversals(user_id);
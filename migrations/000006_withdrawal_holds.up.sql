@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS held NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'CONFIRMED';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS released_at TIMESTAMPTZ;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+UPDATE withdrawals SET created_at = processed_at WHERE created_at IS NULL;
+ALTER TABLE withdrawals ALTER COLUMN created_at SET DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_withdrawals_reserved_expires ON withdrawals(expires_at) WHERE status = 'RESERVED';
@@ -0,0 +1,4 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS order_duplicate BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The unique index on active order numbers depends on WITHDRAW_UNIQUE_ORDER
+-- and is created or dropped at startup by database.SetWithdrawalOrderUnique.
@@ -0,0 +1,4 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS review_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_withdrawals_pending_approval ON withdrawals(created_at) WHERE status = 'PENDING_APPROVAL';
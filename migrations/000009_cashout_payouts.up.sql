@@ -0,0 +1,7 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'ORDER';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS destination TEXT NOT NULL DEFAULT '';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_status TEXT NOT NULL DEFAULT '';
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_ref TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_withdrawals_payout_ref ON withdrawals(payout_ref) WHERE payout_ref IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_withdrawals_payout_status ON withdrawals(payout_status) WHERE payout_status IN ('NEW', 'PENDING');
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    read_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS withdrawal_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reference TEXT NOT NULL,
+    sum NUMERIC(10,2) NOT NULL,
+    period TEXT NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    failures INT NOT NULL DEFAULT 0,
+    last_run_at TIMESTAMPTZ,
+    last_status TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS withdrawal_schedule_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_id UUID NOT NULL REFERENCES withdrawal_schedules(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    withdrawal_id UUID REFERENCES withdrawals(id) ON DELETE SET NULL,
+    error TEXT NOT NULL DEFAULT '',
+    ran_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_schedules_user_id ON withdrawal_schedules(user_id);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_schedules_due ON withdrawal_schedules(next_run_at) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_withdrawal_schedule_runs_schedule_id ON withdrawal_schedule_runs(schedule_id, ran_at);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS accrual_lots (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    amount NUMERIC(10,2) NOT NULL,
+    remaining NUMERIC(10,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expired_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS lot_consumptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    lot_id UUID NOT NULL REFERENCES accrual_lots(id) ON DELETE CASCADE,
+    withdrawal_id UUID NOT NULL REFERENCES withdrawals(id) ON DELETE CASCADE,
+    amount NUMERIC(10,2) NOT NULL,
+    restored NUMERIC(10,2) NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS point_expiries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    lot_id UUID NOT NULL REFERENCES accrual_lots(id) ON DELETE CASCADE,
+    sum NUMERIC(10,2) NOT NULL,
+    expired_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_lots_user_open ON accrual_lots(user_id, created_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_accrual_lots_open ON accrual_lots(created_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_lot_consumptions_withdrawal_id ON lot_consumptions(withdrawal_id);
+CREATE INDEX IF NOT EXISTS idx_point_expiries_user_id ON point_expiries(user_id, expired_at);
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier_updated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS bonuses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    order_number TEXT NOT NULL DEFAULT '',
+    campaign_id UUID,
+    amount NUMERIC(10,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bonuses_user_id ON bonuses(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_accrued ON orders(user_id, accrued_at) WHERE status = 'PROCESSED';
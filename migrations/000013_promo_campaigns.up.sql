@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    value NUMERIC(10,2) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    min_tier TEXT NOT NULL DEFAULT '',
+    first_order_only BOOLEAN NOT NULL DEFAULT FALSE,
+    min_orders INTEGER NOT NULL DEFAULT 0,
+    max_orders INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_window ON campaigns(starts_at, ends_at) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_bonuses_campaign_id ON bonuses(campaign_id) WHERE campaign_id IS NOT NULL;
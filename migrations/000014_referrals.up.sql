@@ -0,0 +1,31 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code TEXT;
+-- Users from before referrals get a code drawn like newReferralCode does;
+-- codes are short, so a taken one is drawn again before the index is built.
+DO $$
+DECLARE
+    u RECORD;
+    c TEXT;
+BEGIN
+    FOR u IN SELECT id FROM users WHERE referral_code IS NULL LOOP
+        LOOP
+            c := UPPER(SUBSTR(MD5(uuid_generate_v4()::text), 1, 8));
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM users WHERE referral_code = c);
+        END LOOP;
+        UPDATE users SET referral_code = c WHERE id = u.id;
+    END LOOP;
+END
+$$;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_referral_code ON users(referral_code);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    referee_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    bonus NUMERIC(10,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    rewarded_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals(referrer_id, created_at);
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS wallets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    current_balance NUMERIC(10,2) NOT NULL DEFAULT 0,
+    withdrawn NUMERIC(10,2) NOT NULL DEFAULT 0,
+    held NUMERIC(10,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS wallet_members (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    can_withdraw BOOLEAN NOT NULL DEFAULT FALSE,
+    max_withdrawal NUMERIC(10,2) NOT NULL DEFAULT 0,
+    route_accruals BOOLEAN NOT NULL DEFAULT FALSE,
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS wallet_invites (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    inviter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invitee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    responded_at TIMESTAMPTZ
+);
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS wallet_id UUID REFERENCES wallets(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS wallet_id UUID REFERENCES wallets(id);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_members_user_id ON wallet_members(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_wallet_members_routing ON wallet_members(user_id) WHERE route_accruals;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_wallet_invites_pending ON wallet_invites(wallet_id, invitee_id) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_wallet_invites_invitee_id ON wallet_invites(invitee_id) WHERE status = 'PENDING';
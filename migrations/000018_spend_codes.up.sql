@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS spend_codes (
+    code TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    withdrawal_id UUID NOT NULL UNIQUE REFERENCES withdrawals(id) ON DELETE CASCADE,
+    merchant_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    redeemed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_spend_codes_user_id ON spend_codes(user_id, created_at);
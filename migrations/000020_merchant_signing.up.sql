@@ -0,0 +1,2 @@
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS signing_secret TEXT;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS signing_required BOOLEAN NOT NULL DEFAULT FALSE;
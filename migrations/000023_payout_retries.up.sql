@@ -0,0 +1,3 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_retry_at TIMESTAMPTZ;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS payout_error TEXT NOT NULL DEFAULT '';
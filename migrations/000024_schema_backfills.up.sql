@@ -0,0 +1,6 @@
+-- One-off data backfills are run by the service at startup; this table
+-- records which of them a database has already had.
+CREATE TABLE IF NOT EXISTS schema_backfills (
+    name TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ DEFAULT NOW()
+);
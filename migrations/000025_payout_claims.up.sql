@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_withdrawals_payout_status;
+CREATE INDEX IF NOT EXISTS idx_withdrawals_payout_open ON withdrawals(payout_status) WHERE payout_status IN ('NEW', 'SUBMITTING', 'PENDING');
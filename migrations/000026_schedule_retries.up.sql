@@ -0,0 +1,2 @@
+ALTER TABLE withdrawal_schedules ADD COLUMN IF NOT EXISTS retry_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE withdrawal_schedules ADD COLUMN IF NOT EXISTS retry_at TIMESTAMPTZ;
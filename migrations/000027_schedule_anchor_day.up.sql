@@ -0,0 +1 @@
+ALTER TABLE withdrawal_schedules ADD COLUMN IF NOT EXISTS anchor_day INT NOT NULL DEFAULT 0;